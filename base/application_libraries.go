@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+)
+
+// ApplicationLibraries are the third-party jars in the WEB-INF/lib directory of a web application, moved into their own
+// launch layer so that a change to the web application's classes does not ship them again.  Snapshot jars change as
+// often as the classes and are left in place.
+type ApplicationLibraries struct {
+	// Source is the WEB-INF/lib directory the jars are moved from.
+	Source string
+
+	layer layers.Layer
+}
+
+// Contribute copies the jars into their layer and removes them from the web application, returning whether there are
+// any.  It is called once any WAR has been expanded or web application migrated, so that their jars are moved too.
+// The jars are removed even if their layer is reused so that they are never part of the application image layer or the
+// layer of an expanded WAR or migrated web application.  If that layer is reused, its jars were moved when it was
+// contributed and the jars recorded in the metadata of their layer are reused.
+//
+// For an exploded web application, removing the jars modifies the application directory.  That is the build's copy of
+// the application, which becomes the application image layer, and not the user's source.
+func (a ApplicationLibraries) Contribute(reused bool) (bool, error) {
+	var m applicationLibrariesMarker
+
+	if reused {
+		if err := a.layer.ReadMetadata(&m); err != nil {
+			return false, err
+		}
+	} else {
+		var err error
+		if m.Jars, err = a.jars(); err != nil {
+			return false, err
+		}
+	}
+
+	if len(m.Jars) == 0 {
+		return false, nil
+	}
+
+	if err := a.layer.Contribute(m, func(layer layers.Layer) error {
+		if err := os.RemoveAll(layer.Root); err != nil {
+			return err
+		}
+
+		layer.Logger.Body("Copying %d jars from %s to %s", len(m.Jars), a.Source, layer.Root)
+		for _, j := range m.names() {
+			if err := helper.CopyFile(filepath.Join(a.Source, j), filepath.Join(layer.Root, j)); err != nil {
+				return err
+			}
+		}
+
+		return internal.Normalize(layer.Root)
+	}, layers.Launch); err != nil {
+		return false, err
+	}
+
+	if reused {
+		return true, nil
+	}
+
+	for _, j := range m.names() {
+		if err := os.Remove(filepath.Join(a.Source, j)); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Root returns the location that the jars are copied to.
+func (a ApplicationLibraries) Root() string {
+	return a.layer.Root
+}
+
+// jars returns the SHA256 hashes of the third-party jars, keyed by name.
+func (a ApplicationLibraries) jars() (map[string]string, error) {
+	files, err := ioutil.ReadDir(a.Source)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	jars := make(map[string]string)
+	for _, f := range files {
+		if !f.Mode().IsRegular() || filepath.Ext(f.Name()) != ".jar" || strings.Contains(f.Name(), "-SNAPSHOT") {
+			continue
+		}
+
+		s, err := sha256File(filepath.Join(a.Source, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		jars[f.Name()] = s
+	}
+
+	return jars, nil
+}
+
+type applicationLibrariesMarker struct {
+	Jars map[string]string `toml:"jars"`
+}
+
+func (m applicationLibrariesMarker) Identity() (string, string) {
+	return "Web Application Libraries", fmt.Sprintf("%d jars", len(m.Jars))
+}
+
+func (m applicationLibrariesMarker) names() []string {
+	var names []string
+	for n := range m.Jars {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewApplicationLibraries creates a new ApplicationLibraries instance for the web application at root, which may not
+// yet have been expanded or migrated.  The layer the jars are moved to is unique to the context path the web
+// application is mounted at.
+func NewApplicationLibraries(root string, contextPath string, layers layers.Layers) ApplicationLibraries {
+	return ApplicationLibraries{filepath.Join(root, "WEB-INF", "lib"), layers.Layer(fmt.Sprintf("lib-%s", contextPath))}
+}
+
+// splitLibraries returns whether the third-party jars of exploded web applications should be moved into their own
+// layers.  Mounting them back into a web application requires the resources configuration of Tomcat 8 and later.
+func splitLibraries(tomcat buildpack.Dependency) (bool, error) {
+	s, ok := os.LookupEnv("BP_TOMCAT_SPLIT_LIBRARIES")
+	if !ok {
+		return false, nil
+	}
+
+	split, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("$BP_TOMCAT_SPLIT_LIBRARIES must be a boolean: %w", err)
+	}
+
+	if split && tomcat.Version.Major() < 8 {
+		return false, fmt.Errorf("$BP_TOMCAT_SPLIT_LIBRARIES requires Tomcat 8 or later, but Tomcat %s is selected", tomcat.Version.Original())
+	}
+
+	return split, nil
+}
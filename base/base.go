@@ -20,10 +20,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"reflect"
-	"regexp"
-	"strconv"
-	"strings"
 
 	"github.com/Masterminds/semver"
 	"github.com/buildpacks/libbuildpack/v2/application"
@@ -31,15 +27,24 @@ import (
 	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
 	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
 	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
 )
 
 const (
 	// AccessLoggingSupportDependency is the id for the Tomcat Access Logging Support contributed to the Tomcat instance.
 	AccessLoggingSupportDependency = "tomcat-access-logging-support"
 
+	// Helper is the id of the buildpack provided helper that configures the Tomcat instance at launch.
+	Helper = "tomcat-helper"
+
 	// ExternalConfiguration is the id for the optional externalized configuration contributed to the Tomcat instance.
 	ExternalConfiguration = "tomcat-external-configuration"
 
+	// JakartaMigrationDependency is the id for the Tomcat Jakarta EE Migration Tool used to migrate web applications
+	// from the Javax to the Jakarta namespace.
+	JakartaMigrationDependency = "tomcat-jakartaee-migration"
+
 	// LifecycleSupportDependency is the id for the Tomcat Lifecycle Support contributed to the Tomcat instance.
 	LifecycleSupportDependency = "tomcat-lifecycle-support"
 
@@ -52,12 +57,24 @@ type Base struct {
 	buildpack   buildpack.Buildpack
 	layer       layers.Layer
 
-	contextPath                string
-	dependencies               []buildpack.Dependency
+	configurationLayer layers.Layer
+	externalLayer      layers.Layer
+	libraryLayer       layers.Layer
+
+	webApplications            []webApplication
+	wars                       []WAR
+	migrations                 []Migration
+	applicationLibraries       map[string]ApplicationLibraries
+	tls                        tlsConfiguration
+	dataSources                dataSourceConfiguration
+	libraries                  []buildpack.Dependency
+	externalConfiguration      externalConfigurationMarker
+	overrides                  []string
 	accessLoggingLayer         layers.DownloadLayer
 	lifecycleLayer             layers.DownloadLayer
 	loggingLayer               layers.DownloadLayer
 	externalConfigurationLayer layers.DownloadLayer
+	helperLayer                layers.HelperLayer
 }
 
 func (b Base) Contribute() error {
@@ -65,16 +82,71 @@ func (b Base) Contribute() error {
 	b.lifecycleLayer.Touch()
 	b.loggingLayer.Touch()
 
-	if !reflect.DeepEqual(b.externalConfigurationLayer, layers.DownloadLayer{}) {
+	if b.hasExternalConfiguration() {
 		b.externalConfigurationLayer.Touch()
 	}
 
-	return b.layer.Contribute(marker{b.contextPath, b.dependencies}, func(layer layers.Layer) error {
+	reused := make(map[string]bool)
+
+	for _, w := range b.wars {
+		r, err := w.Contribute()
+		if err != nil {
+			return err
+		}
+		reused[w.Root()] = r
+	}
+
+	for _, m := range b.migrations {
+		r, err := m.Contribute()
+		if err != nil {
+			return err
+		}
+		reused[m.Root()] = r
+	}
+
+	applications, err := b.contributeApplicationLibraries(reused)
+	if err != nil {
+		return err
+	}
+
+	if err := b.contributeHelper(); err != nil {
+		return err
+	}
+
+	if err := b.contributeLibraries(); err != nil {
+		return err
+	}
+
+	if err := b.contributeExternalConfiguration(); err != nil {
+		return err
+	}
+
+	if err := b.contributeConfiguration(); err != nil {
+		return err
+	}
+
+	roots := []string{b.libraryLayer.Root}
+	if b.hasExternalConfiguration() {
+		roots = append(roots, b.externalLayer.Root)
+	}
+	roots = append(roots, b.configurationLayer.Root)
+
+	links, err := join(roots...)
+	if err != nil {
+		return err
+	}
+
+	inputs := internal.NewInputs()
+	inputs.AddEnvironment("BP_TOMCAT_CONTEXT_PATH", "BP_TOMCAT_CONTEXT_PATHS", "BP_TOMCAT_JAKARTA_MIGRATION",
+		"BP_TOMCAT_SPLIT_LIBRARIES", "BP_TOMCAT_WAR")
+
+	m := marker{applications, b.tls, b.dataSources, links, b.overrides, inputs}
+	return internal.Contribute(b.layer, m, inputs, func(layer layers.Layer) error {
 		if err := os.RemoveAll(layer.Root); err != nil {
 			return err
 		}
 
-		if err := b.contributeConfiguration(layer); err != nil {
+		if err := b.contributeLinks(layer, links); err != nil {
 			return err
 		}
 
@@ -82,15 +154,15 @@ func (b Base) Contribute() error {
 			return err
 		}
 
-		if err := b.contributeLifecycleSupport(layer); err != nil {
+		if err := b.contributeTLS(layer); err != nil {
 			return err
 		}
 
-		if err := b.contributeLoggingSupport(layer); err != nil {
+		if err := b.contributeDataSources(layer); err != nil {
 			return err
 		}
 
-		if err := b.contributeExternalConfiguration(layer); err != nil {
+		if err := b.contributePort(layer); err != nil {
 			return err
 		}
 
@@ -98,29 +170,53 @@ func (b Base) Contribute() error {
 			return err
 		}
 
-		if err := b.contributeApplication(layer); err != nil {
+		if err := b.contributeApplication(layer, applications); err != nil {
+			return err
+		}
+
+		if err := layer.OverrideLaunchEnv("CATALINA_BASE", layer.Root); err != nil {
 			return err
 		}
 
-		return layer.OverrideLaunchEnv("CATALINA_BASE", layer.Root)
+		return internal.Normalize(layer.Root)
 	}, layers.Launch)
 }
 
-func (b Base) contributeAccessLogging(layer layers.Layer) error {
-	layer.Logger.Header("Contributing Access Logging Support")
-	layer.Logger.LaunchConfiguration("Set $BPL_TOMCAT_ACCESS_LOGGING to activate", "inactive")
+// Downloads returns the dependencies that must be downloaded to contribute the layers of the Tomcat base, excluding
+// those whose layers from a previous build can be reused.
+func (b Base) Downloads() ([]buildpack.Dependency, error) {
+	var d []buildpack.Dependency
 
-	artifact, err := b.accessLoggingLayer.Artifact()
-	if err != nil {
-		return err
+	if ok, err := b.libraryLayer.MetadataMatches(b.libraryMarker()); err != nil {
+		return nil, err
+	} else if !ok {
+		d = append(d, b.libraries...)
 	}
 
-	layer.Logger.Body("Copying to %s/lib", layer.Root)
-	if err := helper.CopyFile(artifact, filepath.Join(layer.Root, "lib", filepath.Base(artifact))); err != nil {
-		return err
+	if b.hasExternalConfiguration() {
+		if ok, err := b.externalLayer.MetadataMatches(b.externalConfiguration); err != nil {
+			return nil, err
+		} else if !ok {
+			d = append(d, b.externalConfiguration.Dependency)
+		}
 	}
 
-	return layer.WriteProfile("access-logging", `ENABLED=${BPL_TOMCAT_ACCESS_LOGGING:=n}
+	for _, m := range b.migrations {
+		if ok, err := m.layer.MetadataMatches(m.marker()); err != nil {
+			return nil, err
+		} else if !ok {
+			d = append(d, m.tool)
+		}
+	}
+
+	return d, nil
+}
+
+func (b Base) contributeAccessLogging(layer layers.Layer) error {
+	layer.Logger.Header("Contributing Access Logging Support")
+	layer.Logger.LaunchConfiguration("Set $BPL_TOMCAT_ACCESS_LOGGING to activate", "inactive")
+
+	return b.contributeLaunchConfiguration(layer, "access-logging", `ENABLED=${BPL_TOMCAT_ACCESS_LOGGING:=n}
 
 if [[ "${ENABLED}" = "n" ]]; then
 	return
@@ -132,99 +228,92 @@ export JAVA_OPTS="${JAVA_OPTS} -Daccess.logging.enabled=true"
 `)
 }
 
-func (b Base) contributeApplication(layer layers.Layer) error {
-	cp := filepath.Join(layer.Root, "webapps", b.contextPath)
-
-	layer.Logger.Header("Mounting application at %s", cp)
-
-	return helper.WriteSymlink(b.application.Root, cp)
-}
-
-func (b Base) contributeConfiguration(layer layers.Layer) error {
-	layer.Logger.Header("Contributing Configuration")
-
-	layer.Logger.Body("Copying context.xml to %s/conf", layer.Root)
-	if err := helper.CopyFile(filepath.Join(b.buildpack.Root, "context.xml"), filepath.Join(layer.Root, "conf", "context.xml")); err != nil {
-		return err
-	}
+func (b Base) contributeApplication(layer layers.Layer, applications []webApplication) error {
+	for _, a := range applications {
+		cp := filepath.Join(layer.Root, "webapps", a.ContextPath)
 
-	layer.Logger.Body("Copying logging.properties to %s/conf", layer.Root)
-	if err := helper.CopyFile(filepath.Join(b.buildpack.Root, "logging.properties"), filepath.Join(layer.Root, "conf", "logging.properties")); err != nil {
-		return err
-	}
+		layer.Logger.Header("Mounting application at %s", cp)
 
-	layer.Logger.Body("Copying server.xml to %s/conf", layer.Root)
-	if err := helper.CopyFile(filepath.Join(b.buildpack.Root, "server.xml"), filepath.Join(layer.Root, "conf", "server.xml")); err != nil {
-		return err
-	}
-
-	layer.Logger.Body("Copying web.xml to %s/conf", layer.Root)
-	if err := helper.CopyFile(filepath.Join(b.buildpack.Root, "web.xml"), filepath.Join(layer.Root, "conf", "web.xml")); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (b Base) contributeExternalConfiguration(layer layers.Layer) error {
-	if reflect.DeepEqual(b.externalConfigurationLayer, layers.DownloadLayer{}) {
-		return nil
-	}
+		if err := helper.WriteSymlink(a.Source, cp); err != nil {
+			return err
+		}
 
-	layer.Logger.Header("Contributing External Configuration")
+		if a.Libraries == "" {
+			continue
+		}
 
-	artifact, err := b.externalConfigurationLayer.Artifact()
-	if err != nil {
-		return err
-	}
+		// A context file in the host's configuration directory is merged with the web application mounted at the same
+		// context path.
+		c := filepath.Join(layer.Root, "conf", "Catalina", "localhost", fmt.Sprintf("%s.xml", a.ContextPath))
 
-	layer.Logger.Body("Expanding to %s", layer.Root)
+		layer.Logger.Body("Mounting %s at %s/WEB-INF/lib", a.Libraries, cp)
+		if err := os.RemoveAll(c); err != nil {
+			return err
+		}
 
-	var c int
-	if s, ok := os.LookupEnv("BP_TOMCAT_EXT_CONF_STRIP"); ok {
-		if i, err := strconv.Atoi(s); err != nil {
+		if err := helper.WriteFile(c, 0644, `<?xml version='1.0' encoding='utf-8'?>
+<Context>
+    <Resources allowLinking='true'>
+        <PreResources className='org.apache.catalina.webresources.DirResourceSet' base='%s' webAppMount='/WEB-INF/lib'/>
+    </Resources>
+</Context>
+`, a.Libraries); err != nil {
 			return err
-		} else {
-			c = i
 		}
-	} else {
-		c = 0
 	}
 
-	return helper.ExtractTarGz(artifact, layer.Root, c)
+	return nil
 }
 
-func (b Base) contributeLifecycleSupport(layer layers.Layer) error {
-	layer.Logger.Header("Contributing Lifecycle Support")
+// contributeApplicationLibraries moves the third-party jars of the web applications into their own layers, once any
+// WARs have been expanded and web applications migrated, and returns the web applications with the location of their
+// jars.  Reused is whether the layer of each expanded WAR or migrated web application, keyed by its root, was reused.
+func (b Base) contributeApplicationLibraries(reused map[string]bool) ([]webApplication, error) {
+	var applications []webApplication
+
+	for _, a := range b.webApplications {
+		if l, ok := b.applicationLibraries[a.ContextPath]; ok {
+			if split, err := l.Contribute(reused[a.Source]); err != nil {
+				return nil, err
+			} else if split {
+				a.Libraries = l.Root()
+			}
+		}
 
-	artifact, err := b.lifecycleLayer.Artifact()
-	if err != nil {
-		return err
+		applications = append(applications, a)
 	}
 
-	layer.Logger.Body("Copying to %s/lib", layer.Root)
-	return helper.CopyFile(artifact, filepath.Join(layer.Root, "lib", filepath.Base(artifact)))
+	return applications, nil
 }
 
-func (b Base) contributeLoggingSupport(layer layers.Layer) error {
-	layer.Logger.Header("Contributing Logging Support")
+func (b Base) contributeHelper() error {
+	return b.helperLayer.Contribute(func(artifact string, layer layers.HelperLayer) error {
+		layer.Logger.Body("Copying to %s/bin", layer.Root)
+		return helper.CopyFile(artifact, filepath.Join(layer.Root, "bin", Helper))
+	}, layers.Launch)
+}
 
-	artifact, err := b.loggingLayer.Artifact()
-	if err != nil {
+// contributeLaunchConfiguration links the helper into the layer's exec.d directory under name, so that the helper
+// applies the configuration at launch without a shell.  The profile.d script is a fallback for platforms that do not
+// run exec.d executables and is skipped on those that do.
+func (b Base) contributeLaunchConfiguration(layer layers.Layer, name string, format string, args ...interface{}) error {
+	if err := helper.WriteSymlink(filepath.Join(b.helperLayer.Root, "bin", Helper), filepath.Join(layer.Root, "exec.d", name)); err != nil {
 		return err
 	}
 
-	destination := filepath.Join(layer.Root, "bin", filepath.Base(artifact))
+	return layer.WriteProfile(name, fmt.Sprintf(`if [[ -n "${%s:-}" ]]; then
+	return
+fi
 
-	layer.Logger.Body("Copying to %s/bin", layer.Root)
-	if err := helper.CopyFile(artifact, destination); err != nil {
-		return err
-	}
+`, launch.ExecDMarker)+format, args...)
+}
 
-	layer.Logger.Body("Writing %s/bin/setenv.sh", layer.Root)
-	return helper.WriteFile(filepath.Join(layer.Root, "bin", "setenv.sh"), 0755, `#!/bin/sh
+func (b Base) contributePort(layer layers.Layer) error {
+	layer.Logger.Header("Contributing HTTP Port Configuration")
+	layer.Logger.LaunchConfiguration("Set $PORT to configure the HTTP port", "8080")
 
-CLASSPATH=%s`, destination)
+	return b.contributeLaunchConfiguration(layer, "port", `export JAVA_OPTS="${JAVA_OPTS} -Dport.http=${PORT:-8080}"
+`)
 }
 
 func (Base) contributeTemporaryDirectory(layer layers.Layer) error {
@@ -232,81 +321,163 @@ func (Base) contributeTemporaryDirectory(layer layers.Layer) error {
 }
 
 type marker struct {
-	ContextPath  string                 `toml:"context-path"`
-	Dependencies []buildpack.Dependency `toml:"dependencies"`
+	WebApplications []webApplication        `toml:"web-applications"`
+	TLS             tlsConfiguration        `toml:"tls"`
+	DataSources     dataSourceConfiguration `toml:"data-sources"`
+	Links           []link                  `toml:"links"`
+	Overrides       []string                `toml:"overrides"`
+	Inputs          internal.Inputs         `toml:"inputs"`
 }
 
-func (m marker) Identity() (string, string) {
-	return "Apache Tomcat Support", m.Dependencies[0].Version.Original()
+func (marker) Identity() (string, string) {
+	return "Apache Tomcat Base", ""
 }
 
-// NewBase creates a new CATALINA_BASE instance.  OK is true if the application contains a "WEB-INF" directory.
-func NewBase(build build.Build) (Base, bool, error) {
-	ok, err := helper.FileExists(filepath.Join(build.Application.Root, "WEB-INF"))
+// NewBase creates a new CATALINA_BASE instance for a version of Tomcat.  OK is true if the application contains a
+// "WEB-INF" directory or a WAR file, or if $BP_TOMCAT_CONTEXT_PATHS configures additional web applications.
+func NewBase(build build.Build, tomcat buildpack.Dependency, namespace internal.Namespace) (Base, bool, error) {
+	sources, err := webApplicationSources(build.Application.Root)
 	if err != nil {
 		return Base{}, false, err
 	}
 
-	if !ok {
+	if len(sources) == 0 {
 		return Base{}, false, nil
 	}
 
-	deps, err := build.Buildpack.Dependencies()
+	deps, err := internal.Dependencies(build)
+	if err != nil {
+		return Base{}, false, err
+	}
+
+	var (
+		applications         []webApplication
+		wars                 []WAR
+		migrated             []Migration
+		applicationLibraries = make(map[string]ApplicationLibraries)
+		tool                 []buildpack.Dependency
+	)
+
+	split, err := splitLibraries(tomcat)
 	if err != nil {
 		return Base{}, false, err
 	}
 
+	if m, err := jakartaMigration(build, tomcat, namespace); err != nil {
+		return Base{}, false, err
+	} else if m {
+		if !deps.Has(JakartaMigrationDependency) {
+			return Base{}, false, fmt.Errorf("$BP_TOMCAT_JAKARTA_MIGRATION requires the %s dependency, which is not in buildpack.toml",
+				JakartaMigrationDependency)
+		}
+
+		t, err := deps.Best(JakartaMigrationDependency, "", build.Stack)
+		if err != nil {
+			return Base{}, false, err
+		}
+		tool = append(tool, t)
+
+		if applications, migrated, err = migrations(build, sources, t, split); err != nil {
+			return Base{}, false, err
+		}
+	} else if applications, wars, err = webApplications(sources, build.Layers, split); err != nil {
+		return Base{}, false, err
+	}
+
+	if split {
+		for _, a := range applications {
+			applicationLibraries[a.ContextPath] = NewApplicationLibraries(a.Source, a.ContextPath, build.Layers)
+		}
+	}
+
 	var d []buildpack.Dependency
 
-	al, err := deps.Best(AccessLoggingSupportDependency, "", build.Stack)
+	al, err := compatibleDependency(build, deps, AccessLoggingSupportDependency, tomcat)
 	if err != nil {
 		return Base{}, false, err
 	}
 	d = append(d, al)
 
-	lc, err := deps.Best(LifecycleSupportDependency, "", build.Stack)
+	lc, err := compatibleDependency(build, deps, LifecycleSupportDependency, tomcat)
 	if err != nil {
 		return Base{}, false, err
 	}
 	d = append(d, lc)
 
-	log, err := deps.Best(LoggingSupportDependency, "", build.Stack)
+	log, err := compatibleDependency(build, deps, LoggingSupportDependency, tomcat)
 	if err != nil {
 		return Base{}, false, err
 	}
 	d = append(d, log)
 
-	var externalConfigurationLayer layers.DownloadLayer
+	var (
+		external                   externalConfigurationMarker
+		externalConfigurationLayer layers.DownloadLayer
+	)
 	if e, ok, err := externalConfiguration(build, deps); err != nil {
 		return Base{}, false, err
 	} else if ok {
+		strip, err := externalConfigurationStrip()
+		if err != nil {
+			return Base{}, false, err
+		}
+
 		d = append(d, e)
+		inputs := internal.NewInputs()
+		inputs.AddEnvironment("BP_TOMCAT_EXT_CONF_STRIP")
+		inputs.AddDependency(e)
+
+		external = externalConfigurationMarker{e, strip, inputs}
 		externalConfigurationLayer = build.Layers.DownloadLayer(e)
 	}
 
+	tls, err := newTLSConfiguration(build, tomcat)
+	if err != nil {
+		return Base{}, false, err
+	}
+
+	dataSources := newDataSourceConfiguration(build, tomcat)
+	d = append(d, tool...)
+
+	var overrides []string
+	for _, dep := range d {
+		if internal.Overridden(dep.ID) {
+			build.Logger.HeaderWarning("Using user supplied %s %s", dep.Name, dep.Version.Original())
+			overrides = append(overrides, dep.ID)
+		}
+	}
+
+	var libraries []buildpack.Dependency
+	for _, dep := range d {
+		if dep.ID != ExternalConfiguration && dep.ID != JakartaMigrationDependency {
+			libraries = append(libraries, dep)
+		}
+	}
+
 	return Base{
 		build.Application,
 		build.Buildpack,
 		build.Layers.Layer("catalina-base"),
-		contextPath(),
-		d,
+		build.Layers.Layer("catalina-base-conf"),
+		build.Layers.Layer("catalina-base-ext-conf"),
+		build.Layers.Layer("catalina-base-lib"),
+		applications,
+		wars,
+		migrated,
+		applicationLibraries,
+		tls,
+		dataSources,
+		libraries,
+		external,
+		overrides,
 		build.Layers.DownloadLayer(al),
 		build.Layers.DownloadLayer(lc),
 		build.Layers.DownloadLayer(log),
 		externalConfigurationLayer,
+		build.Layers.HelperLayer(Helper, "Apache Tomcat Helper"),
 	}, true, nil
 }
 
-func contextPath() string {
-	cp, ok := os.LookupEnv("BP_TOMCAT_CONTEXT_PATH")
-	if !ok {
-		cp = "ROOT"
-	}
-
-	cp = regexp.MustCompile("^/").ReplaceAllString(cp, "")
-	return strings.ReplaceAll(cp, "/", "#")
-}
-
 func externalConfiguration(build build.Build, deps buildpack.Dependencies) (buildpack.Dependency, bool, error) {
 	v, vOk := os.LookupEnv("BP_TOMCAT_EXT_CONF_VERSION")
 	u, uOk := os.LookupEnv("BP_TOMCAT_EXT_CONF_URI")
@@ -17,7 +17,10 @@
 package base_test
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -26,8 +29,11 @@ import (
 	"github.com/Masterminds/semver"
 	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
 	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/services"
 	"github.com/cloudfoundry/libcfbuildpack/v2/test"
 	"github.com/cloudfoundry/tomcat-cnb/base"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	itest "github.com/cloudfoundry/tomcat-cnb/internal/test"
 	"github.com/onsi/gomega"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -40,40 +46,129 @@ func TestBase(t *testing.T) {
 
 		var f *test.BuildFactory
 
+		tomcat := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse("9.0.0")}}
+
 		it.Before(func() {
 			f = test.NewBuildFactory(t)
+
+			f.AddDependency("tomcat-access-logging-support", filepath.Join("testdata", "stub-tomcat-access-logging-support.jar"))
+			f.AddDependency("tomcat-lifecycle-support", filepath.Join("testdata", "stub-tomcat-lifecycle-support.jar"))
+			f.AddDependency("tomcat-logging-support", filepath.Join("testdata", "stub-tomcat-logging-support.jar"))
+			test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "context.xml"))
+			test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "logging.properties"))
+			test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "server.xml"))
+			test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "web.xml"))
+			test.TouchFile(t, f.Build.Buildpack.Root, "bin", "tomcat-helper")
 		})
 
-		it("returns false with no WEB-INF", func() {
-			_, ok, err := base.NewBase(f.Build)
+		it("returns false with no WEB-INF or WAR", func() {
+			_, ok, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 			g.Expect(err).NotTo(gomega.HaveOccurred())
 			g.Expect(ok).To(gomega.BeFalse())
 		})
 
+		when("WAR application", func() {
+
+			it("expands WAR and links it to ROOT", func() {
+				if err := helper.CopyFile(filepath.Join("testdata", "stub-application.war"),
+					filepath.Join(f.Build.Application.Root, "stub-application.war")); err != nil {
+					t.Fatal(err)
+				}
+
+				b, ok, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(ok).To(gomega.BeTrue())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				war := f.Build.Layers.Layer("war-ROOT")
+				g.Expect(war).To(test.HaveLayerMetadata(false, false, true))
+				g.Expect(filepath.Join(war.Root, "fixture-marker")).To(gomega.BeAnExistingFile())
+
+				layer := f.Build.Layers.Layer("catalina-base")
+				g.Expect(filepath.Join(layer.Root, "webapps", "ROOT")).To(test.BeASymlink(war.Root))
+			})
+
+			it("expands $BP_TOMCAT_WAR", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_WAR", "target/stub-application.war")()
+
+				if err := helper.CopyFile(filepath.Join("testdata", "stub-application.war"),
+					filepath.Join(f.Build.Application.Root, "target", "stub-application.war")); err != nil {
+					t.Fatal(err)
+				}
+				test.TouchFile(t, f.Build.Application.Root, "other.war")
+
+				b, ok, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(ok).To(gomega.BeTrue())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				war := f.Build.Layers.Layer("war-ROOT")
+				g.Expect(filepath.Join(war.Root, "fixture-marker")).To(gomega.BeAnExistingFile())
+			})
+
+			it("ignores $BP_TOMCAT_CONTEXT_PATHS WARs when finding the primary WAR", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATHS", "admin=admin.war")()
+
+				for _, name := range []string{"stub-application.war", "admin.war"} {
+					if err := helper.CopyFile(filepath.Join("testdata", "stub-application.war"),
+						filepath.Join(f.Build.Application.Root, name)); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				b, ok, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(ok).To(gomega.BeTrue())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("catalina-base")
+				g.Expect(filepath.Join(layer.Root, "webapps", "ROOT")).To(test.BeASymlink(f.Build.Layers.Layer("war-ROOT").Root))
+				g.Expect(filepath.Join(layer.Root, "webapps", "admin")).To(test.BeASymlink(f.Build.Layers.Layer("war-admin").Root))
+			})
+
+			it("fails with missing $BP_TOMCAT_WAR", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_WAR", "missing.war")()
+
+				_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_WAR missing.war does not exist"))
+			})
+
+			it("fails with $BP_TOMCAT_WAR outside of the application root", func() {
+				test.TouchFile(t, filepath.Dir(f.Build.Application.Root), "outside.war")
+				defer test.ReplaceEnv(t, "BP_TOMCAT_WAR", "../outside.war")()
+
+				_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_WAR ../outside.war is outside of the application root"))
+			})
+
+			it("fails with multiple WARs", func() {
+				test.TouchFile(t, f.Build.Application.Root, "alpha.war")
+				test.TouchFile(t, f.Build.Application.Root, "bravo.war")
+
+				_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("multiple WAR files found in application root, set $BP_TOMCAT_WAR to select one"))
+			})
+		})
+
 		when("valid application", func() {
 
 			it.Before(func() {
-				f.AddDependency("tomcat-access-logging-support", filepath.Join("testdata", "stub-tomcat-access-logging-support.jar"))
-				f.AddDependency("tomcat-lifecycle-support", filepath.Join("testdata", "stub-tomcat-lifecycle-support.jar"))
-				f.AddDependency("tomcat-logging-support", filepath.Join("testdata", "stub-tomcat-logging-support.jar"))
-				test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "context.xml"))
-				test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "logging.properties"))
-				test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "server.xml"))
-				test.TouchFile(t, filepath.Join(f.Build.Buildpack.Root, "web.xml"))
-
 				if err := os.MkdirAll(filepath.Join(f.Build.Application.Root, "WEB-INF"), 0755); err != nil {
 					t.Fatal(err)
 				}
 			})
 
 			it("returns true with jvm-application and WEB-INF", func() {
-				_, ok, err := base.NewBase(f.Build)
+				_, ok, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 				g.Expect(ok).To(gomega.BeTrue())
 			})
 
 			it("links application to ROOT", func() {
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -85,7 +180,7 @@ func TestBase(t *testing.T) {
 			it("links application to BP_TOMCAT_CONTEXT_PATH", func() {
 				defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATH", "foo/bar")()
 
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -94,28 +189,355 @@ func TestBase(t *testing.T) {
 				g.Expect(filepath.Join(layer.Root, "webapps", "foo#bar")).To(test.BeASymlink(f.Build.Application.Root))
 			})
 
+			it("links $BP_TOMCAT_CONTEXT_PATHS applications", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATHS", "admin=admin.war, /docs/v1=docs")()
+
+				if err := helper.CopyFile(filepath.Join("testdata", "stub-application.war"),
+					filepath.Join(f.Build.Application.Root, "admin.war")); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(filepath.Join(f.Build.Application.Root, "docs"), 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				war := f.Build.Layers.Layer("war-admin")
+				g.Expect(filepath.Join(war.Root, "fixture-marker")).To(gomega.BeAnExistingFile())
+
+				layer := f.Build.Layers.Layer("catalina-base")
+				g.Expect(filepath.Join(layer.Root, "webapps", "ROOT")).To(test.BeASymlink(f.Build.Application.Root))
+				g.Expect(filepath.Join(layer.Root, "webapps", "admin")).To(test.BeASymlink(war.Root))
+				g.Expect(filepath.Join(layer.Root, "webapps", "docs#v1")).To(test.BeASymlink(filepath.Join(f.Build.Application.Root, "docs")))
+			})
+
+			it("fails with $BP_TOMCAT_CONTEXT_PATHS application at the primary context path", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATHS", "/=docs")()
+
+				if err := os.MkdirAll(filepath.Join(f.Build.Application.Root, "docs"), 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError(fmt.Sprintf("both %s and %s are mounted at context path ROOT",
+					f.Build.Application.Root, filepath.Join(f.Build.Application.Root, "docs"))))
+			})
+
+			it("fails with $BP_TOMCAT_CONTEXT_PATHS outside of the application root", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATHS", "admin=../admin.war")()
+
+				_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_CONTEXT_PATHS path ../admin.war is outside of the application root"))
+			})
+
+			it("fails with malformed $BP_TOMCAT_CONTEXT_PATHS", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATHS", "admin")()
+
+				_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_CONTEXT_PATHS entry admin must be of the form <context-path>=<path>"))
+			})
+
 			it("contributes configuration", func() {
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("catalina-base")
+				conf := f.Build.Layers.Layer("catalina-base-conf")
+				g.Expect(conf).To(test.HaveLayerMetadata(false, true, true))
+				g.Expect(filepath.Join(layer.Root, "conf", "context.xml")).To(test.BeASymlink(filepath.Join(conf.Root, "conf", "context.xml")))
+				g.Expect(filepath.Join(layer.Root, "conf", "logging.properties")).To(test.BeASymlink(filepath.Join(conf.Root, "conf", "logging.properties")))
+				g.Expect(filepath.Join(layer.Root, "conf", "server.xml")).To(test.BeASymlink(filepath.Join(conf.Root, "conf", "server.xml")))
+				g.Expect(filepath.Join(layer.Root, "conf", "web.xml")).To(test.BeASymlink(filepath.Join(conf.Root, "conf", "web.xml")))
+			})
+
+			it("contributes libraries to their own layer", func() {
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("catalina-base")
+				lib := f.Build.Layers.Layer("catalina-base-lib")
+				g.Expect(lib).To(test.HaveLayerMetadata(false, true, true))
+				g.Expect(filepath.Join(layer.Root, "lib", "stub-tomcat-access-logging-support.jar")).
+					To(test.BeASymlink(filepath.Join(lib.Root, "lib", "stub-tomcat-access-logging-support.jar")))
+				g.Expect(filepath.Join(layer.Root, "lib", "stub-tomcat-lifecycle-support.jar")).
+					To(test.BeASymlink(filepath.Join(lib.Root, "lib", "stub-tomcat-lifecycle-support.jar")))
+			})
+
+			it("contributes only the layers whose inputs change", func() {
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				conf := f.Build.Layers.Layer("catalina-base-conf")
+				lib := f.Build.Layers.Layer("catalina-base-lib")
+				test.TouchFile(t, conf.Root, "conf", "sentinel")
+				test.TouchFile(t, lib.Root, "lib", "sentinel")
+
+				f.AddDependency("tomcat-external-configuration", filepath.Join("testdata", "stub-external-configuration.tar.gz"))
+
+				b, _, err = base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("catalina-base")
+				external := f.Build.Layers.Layer("catalina-base-ext-conf")
+				g.Expect(external).To(test.HaveLayerMetadata(false, true, true))
+				g.Expect(filepath.Join(layer.Root, "fixture-marker")).To(test.BeASymlink(filepath.Join(external.Root, "fixture-marker")))
+				g.Expect(filepath.Join(conf.Root, "conf", "sentinel")).NotTo(gomega.BeAnExistingFile())
+				g.Expect(filepath.Join(lib.Root, "lib", "sentinel")).To(gomega.BeAnExistingFile())
+			})
+
+			it("reuses layers with unchanged inputs", func() {
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				for _, l := range []string{"catalina-base", "catalina-base-conf", "catalina-base-lib"} {
+					test.TouchFile(t, f.Build.Layers.Layer(l).Root, "sentinel")
+				}
+
+				b, _, err = base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				for _, l := range []string{"catalina-base", "catalina-base-conf", "catalina-base-lib"} {
+					g.Expect(filepath.Join(f.Build.Layers.Layer(l).Root, "sentinel")).To(gomega.BeAnExistingFile())
+				}
+			})
+
+			it("downloads libraries unless reused", func() {
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Downloads()).To(gomega.HaveLen(3))
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				g.Expect(b.Downloads()).To(gomega.BeEmpty())
+			})
+
+			it("contributes configuration again when a buildpack configuration file changes", func() {
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				test.WriteFile(t, filepath.Join(f.Build.Buildpack.Root, "server.xml"), "<Server/>")
+
+				b, _, err = base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
 
 				layer := f.Build.Layers.Layer("catalina-base")
-				g.Expect(filepath.Join(layer.Root, "conf", "context.xml")).To(gomega.BeAnExistingFile())
-				g.Expect(filepath.Join(layer.Root, "conf", "logging.properties")).To(gomega.BeAnExistingFile())
-				g.Expect(filepath.Join(layer.Root, "conf", "server.xml")).To(gomega.BeAnExistingFile())
-				g.Expect(filepath.Join(layer.Root, "conf", "web.xml")).To(gomega.BeAnExistingFile())
+				g.Expect(filepath.Join(layer.Root, "conf", "server.xml")).To(test.HaveContent("<Server/>"))
+			})
+
+			it("contributes configuration again when $BP_TOMCAT_EXT_CONF_STRIP changes", func() {
+				f.AddDependency("tomcat-external-configuration", filepath.Join("testdata", "stub-external-configuration.tar.gz"))
+
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				conf := f.Build.Layers.Layer("catalina-base-conf")
+				external := f.Build.Layers.Layer("catalina-base-ext-conf")
+				test.TouchFile(t, conf.Root, "sentinel")
+				test.TouchFile(t, external.Root, "sentinel")
+
+				defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_STRIP", "0")()
+
+				b, _, err = base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				g.Expect(filepath.Join(conf.Root, "sentinel")).NotTo(gomega.BeAnExistingFile())
+				g.Expect(filepath.Join(external.Root, "sentinel")).NotTo(gomega.BeAnExistingFile())
+			})
+
+			when("split libraries", func() {
+
+				it.Before(func() {
+					test.WriteFile(t, filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "library-1.0.0.jar"), "test-library")
+					test.WriteFile(t, filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "module-1.0.0-SNAPSHOT.jar"), "test-module")
+				})
+
+				it("does not split libraries without $BP_TOMCAT_SPLIT_LIBRARIES", func() {
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					g.Expect(filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "library-1.0.0.jar")).To(gomega.BeARegularFile())
+					g.Expect(filepath.Join(f.Build.Layers.Layer("catalina-base").Root, "conf", "Catalina")).NotTo(gomega.BeAnExistingFile())
+				})
+
+				it("moves third-party jars to their own layer", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_SPLIT_LIBRARIES", "true")()
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					lib := f.Build.Layers.Layer("lib-ROOT")
+					g.Expect(lib).To(test.HaveLayerMetadata(false, false, true))
+					g.Expect(filepath.Join(lib.Root, "library-1.0.0.jar")).To(test.HaveContent("test-library"))
+					g.Expect(filepath.Join(lib.Root, "module-1.0.0-SNAPSHOT.jar")).NotTo(gomega.BeAnExistingFile())
+
+					g.Expect(filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "library-1.0.0.jar")).NotTo(gomega.BeAnExistingFile())
+					g.Expect(filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "module-1.0.0-SNAPSHOT.jar")).To(gomega.BeARegularFile())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "conf", "Catalina", "localhost", "ROOT.xml")).To(test.HaveContent(fmt.Sprintf(`<?xml version='1.0' encoding='utf-8'?>
+<Context>
+    <Resources allowLinking='true'>
+        <PreResources className='org.apache.catalina.webresources.DirResourceSet' base='%s' webAppMount='/WEB-INF/lib'/>
+    </Resources>
+</Context>
+`, lib.Root)))
+				})
+
+				it("removes jars from the application when the layer is reused", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_SPLIT_LIBRARIES", "true")()
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					lib := f.Build.Layers.Layer("lib-ROOT")
+					g.Expect(os.RemoveAll(lib.Root)).To(gomega.Succeed())
+					test.WriteFile(t, filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "library-1.0.0.jar"), "test-library")
+
+					b, _, err = base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					g.Expect(lib.Root).NotTo(gomega.BeAnExistingFile())
+					g.Expect(filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "library-1.0.0.jar")).NotTo(gomega.BeAnExistingFile())
+				})
+
+				when("WAR application", func() {
+
+					it.Before(func() {
+						g.Expect(os.RemoveAll(filepath.Join(f.Build.Application.Root, "WEB-INF"))).To(gomega.Succeed())
+
+						var b bytes.Buffer
+						w := zip.NewWriter(&b)
+						for _, n := range []string{"WEB-INF/lib/library-1.0.0.jar", "WEB-INF/classes/Test.class"} {
+							e, err := w.Create(n)
+							g.Expect(err).NotTo(gomega.HaveOccurred())
+							_, err = e.Write([]byte("test-content"))
+							g.Expect(err).NotTo(gomega.HaveOccurred())
+						}
+						g.Expect(w.Close()).To(gomega.Succeed())
+
+						test.WriteFile(t, filepath.Join(f.Build.Application.Root, "stub-application.war"), "%s", b.String())
+					})
+
+					it("moves third-party jars of the expanded WAR to their own layer", func() {
+						defer test.ReplaceEnv(t, "BP_TOMCAT_SPLIT_LIBRARIES", "true")()
+
+						b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+						g.Expect(err).NotTo(gomega.HaveOccurred())
+
+						g.Expect(b.Contribute()).To(gomega.Succeed())
+
+						lib := f.Build.Layers.Layer("lib-ROOT")
+						g.Expect(filepath.Join(lib.Root, "library-1.0.0.jar")).To(test.HaveContent("test-content"))
+
+						war := f.Build.Layers.Layer("war-ROOT")
+						g.Expect(filepath.Join(war.Root, "WEB-INF", "lib", "library-1.0.0.jar")).NotTo(gomega.BeAnExistingFile())
+						g.Expect(filepath.Join(war.Root, "WEB-INF", "classes", "Test.class")).To(gomega.BeARegularFile())
+
+						layer := f.Build.Layers.Layer("catalina-base")
+						g.Expect(filepath.Join(layer.Root, "conf", "Catalina", "localhost", "ROOT.xml")).To(gomega.BeARegularFile())
+					})
+
+					it("reuses libraries layer when the expanded WAR is reused", func() {
+						defer test.ReplaceEnv(t, "BP_TOMCAT_SPLIT_LIBRARIES", "true")()
+
+						b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+						g.Expect(err).NotTo(gomega.HaveOccurred())
+
+						g.Expect(b.Contribute()).To(gomega.Succeed())
+
+						// Launch layers are not restored, only their metadata
+						lib := f.Build.Layers.Layer("lib-ROOT")
+						war := f.Build.Layers.Layer("war-ROOT")
+						g.Expect(os.RemoveAll(lib.Root)).To(gomega.Succeed())
+						g.Expect(os.RemoveAll(war.Root)).To(gomega.Succeed())
+
+						b, _, err = base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+						g.Expect(err).NotTo(gomega.HaveOccurred())
+
+						g.Expect(b.Contribute()).To(gomega.Succeed())
+
+						g.Expect(lib).To(test.HaveLayerMetadata(false, false, true))
+						g.Expect(war.Root).NotTo(gomega.BeAnExistingFile())
+
+						layer := f.Build.Layers.Layer("catalina-base")
+						g.Expect(filepath.Join(layer.Root, "conf", "Catalina", "localhost", "ROOT.xml")).To(gomega.BeARegularFile())
+					})
+
+					it("expands the WAR again when $BP_TOMCAT_SPLIT_LIBRARIES changes", func() {
+						defer test.ReplaceEnv(t, "BP_TOMCAT_SPLIT_LIBRARIES", "true")()
+
+						b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+						g.Expect(err).NotTo(gomega.HaveOccurred())
+
+						g.Expect(b.Contribute()).To(gomega.Succeed())
+
+						defer test.ReplaceEnv(t, "BP_TOMCAT_SPLIT_LIBRARIES", "false")()
+
+						b, _, err = base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+						g.Expect(err).NotTo(gomega.HaveOccurred())
+
+						g.Expect(b.Contribute()).To(gomega.Succeed())
+
+						war := f.Build.Layers.Layer("war-ROOT")
+						g.Expect(filepath.Join(war.Root, "WEB-INF", "lib", "library-1.0.0.jar")).To(gomega.BeARegularFile())
+					})
+				})
+
+				it("fails with Tomcat 7", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_SPLIT_LIBRARIES", "true")()
+
+					tomcat7 := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse("7.0.0")}}
+
+					_, _, err := base.NewBase(f.Build, tomcat7, internal.UnknownNamespace)
+					g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_SPLIT_LIBRARIES requires Tomcat 8 or later, but Tomcat 7.0.0 is selected"))
+				})
 			})
 
 			it("contributes access logging support", func() {
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
 
 				layer := f.Build.Layers.Layer("catalina-base")
 				g.Expect(filepath.Join(layer.Root, "lib", "stub-tomcat-access-logging-support.jar")).To(gomega.BeAnExistingFile())
-				g.Expect(layer).To(test.HaveProfile("access-logging", `ENABLED=${BPL_TOMCAT_ACCESS_LOGGING:=n}
+				g.Expect(layer).To(test.HaveProfile("access-logging", `if [[ -n "${BPI_TOMCAT_EXEC_D:-}" ]]; then
+	return
+fi
+
+ENABLED=${BPL_TOMCAT_ACCESS_LOGGING:=n}
 
 if [[ "${ENABLED}" = "n" ]]; then
 	return
@@ -125,10 +547,12 @@ printf "Tomcat Access Logging enabled\n"
 
 export JAVA_OPTS="${JAVA_OPTS} -Daccess.logging.enabled=true"
 `))
+				g.Expect(filepath.Join(layer.Root, "exec.d", "access-logging")).
+					To(test.BeASymlink(filepath.Join(f.Build.Layers.Layer("tomcat-helper").Root, "bin", "tomcat-helper")))
 			})
 
 			it("contributes lifecycle support", func() {
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -138,22 +562,419 @@ export JAVA_OPTS="${JAVA_OPTS} -Daccess.logging.enabled=true"
 			})
 
 			it("contributes logging support", func() {
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
 
 				layer := f.Build.Layers.Layer("catalina-base")
-				destination := filepath.Join(layer.Root, "bin", "stub-tomcat-logging-support.jar")
-				g.Expect(destination).To(gomega.BeAnExistingFile())
+				destination := filepath.Join(f.Build.Layers.Layer("catalina-base-lib").Root, "bin", "stub-tomcat-logging-support.jar")
+				g.Expect(filepath.Join(layer.Root, "bin", "stub-tomcat-logging-support.jar")).To(test.BeASymlink(destination))
 				g.Expect(filepath.Join(layer.Root, "bin", "setenv.sh")).To(test.HavePermissions(0755))
 				g.Expect(filepath.Join(layer.Root, "bin", "setenv.sh")).To(test.HaveContent(fmt.Sprintf(`#!/bin/sh
 
 CLASSPATH=%s`, destination)))
 			})
 
+			it("records overrides in layer metadata", func() {
+				deps, err := f.Build.Buildpack.Dependencies()
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				var d buildpack.Dependency
+				for _, dep := range deps {
+					if dep.ID == "tomcat-logging-support" {
+						d = dep
+					}
+				}
+
+				defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_VERSION", d.Version.Original())()
+				defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_URI", d.URI)()
+				defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_SHA256", d.SHA256)()
+
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				var metadata struct {
+					Metadata struct {
+						Overrides []string `toml:"overrides"`
+					} `toml:"metadata"`
+				}
+				_, err = toml.DecodeFile(f.Build.Layers.Layer("catalina-base").Metadata, &metadata)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(metadata.Metadata.Overrides).To(gomega.Equal([]string{"tomcat-logging-support"}))
+			})
+
+			it("contributes port configuration", func() {
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("catalina-base")
+				g.Expect(layer).To(test.HaveProfile("port", `if [[ -n "${BPI_TOMCAT_EXEC_D:-}" ]]; then
+	return
+fi
+
+export JAVA_OPTS="${JAVA_OPTS} -Dport.http=${PORT:-8080}"
+`))
+				g.Expect(filepath.Join(layer.Root, "exec.d", "port")).
+					To(test.BeASymlink(filepath.Join(f.Build.Layers.Layer("tomcat-helper").Root, "bin", "tomcat-helper")))
+			})
+
+			when("TLS service", func() {
+
+				it.Before(func() {
+					test.WriteFile(t, filepath.Join(f.Build.Buildpack.Root, "server.xml"), `<Server>
+    <Service>
+        <Connector/>
+        <Engine/>
+    </Service>
+</Server>
+`)
+					test.WriteFile(t, filepath.Join(f.Build.Buildpack.Root, "web.xml"), `<web-app>
+</web-app>
+`)
+				})
+
+				it("does not contribute HTTPS connector without TLS service", func() {
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "conf", "server.xml")).To(test.HaveContent(`<Server>
+    <Service>
+        <Connector/>
+        <Engine/>
+    </Service>
+</Server>
+`))
+					g.Expect(filepath.Join(layer.Root, ".profile.d", "tls")).NotTo(gomega.BeAnExistingFile())
+					g.Expect(filepath.Join(layer.Root, "exec.d", "tls")).NotTo(gomega.BeAnExistingFile())
+				})
+
+				it("contributes HTTPS connector", func() {
+					f.AddService("tls", services.Credentials{"certificate": "", "private_key": ""})
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "conf", "server.xml")).To(test.HaveContent(`<Server>
+    <Service>
+        <Connector/>
+<!-- BEGIN https-connector -->
+        <Connector port='${port.https}' SSLEnabled='true' scheme='https' secure='true' bindOnInit='false' connectionTimeout='20000'>
+            <SSLHostConfig>
+                <Certificate certificateFile='${tomcat.tls.directory}/certificate.pem'
+                             certificateKeyFile='${tomcat.tls.directory}/private-key.pem'/>
+            </SSLHostConfig>
+        </Connector>
+<!-- END https-connector -->
+        <Engine/>
+    </Service>
+</Server>
+`))
+					g.Expect(filepath.Join(layer.Root, "conf", "web.xml")).To(test.HaveContent(`<web-app>
+</web-app>
+`))
+					g.Expect(layer).To(test.HaveProfile("tls", `if [[ -n "${BPI_TOMCAT_EXEC_D:-}" ]]; then
+	return
+fi
+
+tomcat-helper tls "${TMPDIR:-/tmp}/tomcat/tls"
+
+export JAVA_OPTS="${JAVA_OPTS} -Dport.https=${BPL_TOMCAT_HTTPS_PORT:-8443} -Dtomcat.tls.directory=${TMPDIR:-/tmp}/tomcat/tls"
+`))
+
+					helper := f.Build.Layers.Layer("tomcat-helper")
+					g.Expect(helper).To(test.HaveLayerMetadata(false, false, true))
+					g.Expect(filepath.Join(helper.Root, "bin", "tomcat-helper")).To(gomega.BeARegularFile())
+				})
+
+				it("contributes HTTPS connector with certificate chain", func() {
+					f.AddService("tls", services.Credentials{"certificate": "", "certificate_chain": "", "private_key": ""})
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(ioutil.ReadFile(filepath.Join(layer.Root, "conf", "server.xml"))).To(gomega.ContainSubstring(`
+                             certificateChainFile='${tomcat.tls.directory}/certificate-chain.pem'/>`))
+				})
+
+				it("fails with TLS service for Tomcat 8.0", func() {
+					f.AddService("tls", services.Credentials{"certificate": "", "private_key": ""})
+
+					tomcat8 := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse("8.0.53")}}
+					_, _, err := base.NewBase(f.Build, tomcat8, internal.UnknownNamespace)
+					g.Expect(err).To(gomega.MatchError("a tls service binding requires Tomcat 8.5 or later, but Tomcat 8.0.53 is used"))
+				})
+
+				it("contributes HTTPS redirect with $BP_TOMCAT_HTTPS_REDIRECT", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_HTTPS_REDIRECT", "true")()
+					f.AddService("tls", services.Credentials{"certificate": "", "private_key": ""})
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(ioutil.ReadFile(filepath.Join(layer.Root, "conf", "server.xml"))).To(gomega.ContainSubstring(`
+        <Connector redirectPort='${port.https}'/>`))
+					g.Expect(filepath.Join(layer.Root, "conf", "web.xml")).To(test.HaveContent(`<web-app>
+<!-- BEGIN https-redirect -->
+    <security-constraint>
+        <web-resource-collection>
+            <web-resource-name>HTTPS Redirect</web-resource-name>
+            <url-pattern>/*</url-pattern>
+        </web-resource-collection>
+        <user-data-constraint>
+            <transport-guarantee>CONFIDENTIAL</transport-guarantee>
+        </user-data-constraint>
+    </security-constraint>
+<!-- END https-redirect -->
+</web-app>
+`))
+				})
+			})
+
+			when("database service", func() {
+
+				it.Before(func() {
+					test.WriteFile(t, filepath.Join(f.Build.Buildpack.Root, "context.xml"), `<Context>
+</Context>
+`)
+				})
+
+				it("does not contribute DataSources without database service", func() {
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, ".profile.d", "datasources")).NotTo(gomega.BeAnExistingFile())
+				})
+
+				it("contributes DataSources", func() {
+					f.AddService("orders", services.Credentials{"uri": ""}, "postgres")
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "conf", "context.xml")).To(test.HaveContent(`<Context>
+<!-- BEGIN datasources -->
+    <Resource name="jdbc/orders" auth="Container" type="javax.sql.DataSource"
+              driverClassName="org.postgresql.Driver" url="${tomcat.datasource.jdbc/orders.url}" username="${tomcat.datasource.jdbc/orders.username}" password="${tomcat.datasource.jdbc/orders.password}"
+              maxTotal="${tomcat.datasource.max-total}" maxIdle="${tomcat.datasource.max-idle}" maxWaitMillis="${tomcat.datasource.max-wait-millis}"/>
+<!-- END datasources -->
+</Context>
+`))
+					g.Expect(layer).To(test.HaveProfile("datasources", `if [[ -n "${BPI_TOMCAT_EXEC_D:-}" ]]; then
+	return
+fi
+
+tomcat-helper datasources "${TMPDIR:-/tmp}/tomcat/catalina.properties"
+export JAVA_OPTS="${JAVA_OPTS} -Dcatalina.config=file:${TMPDIR:-/tmp}/tomcat/catalina.properties"
+`))
+					g.Expect(filepath.Join(f.Build.Layers.Layer("tomcat-helper").Root, "bin", "tomcat-helper")).To(gomega.BeAnExistingFile())
+				})
+
+				it("contributes DataSources with DBCP 1 attributes for Tomcat 7", func() {
+					f.AddService("orders", services.Credentials{"uri": ""}, "postgres")
+
+					tomcat7 := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse("7.0.0")}}
+					b, _, err := base.NewBase(f.Build, tomcat7, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(ioutil.ReadFile(filepath.Join(layer.Root, "conf", "context.xml"))).To(gomega.ContainSubstring(
+						`maxActive="${tomcat.datasource.max-total}" maxIdle="${tomcat.datasource.max-idle}" maxWait="${tomcat.datasource.max-wait-millis}"/>`))
+				})
+			})
+
+			it("fails Jakarta migration without migration tool", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "true")()
+
+				tomcat10 := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse("10.0.0")}}
+
+				_, _, err := base.NewBase(f.Build, tomcat10, internal.Javax)
+				g.Expect(err).To(gomega.MatchError(
+					"$BP_TOMCAT_JAKARTA_MIGRATION requires the tomcat-jakartaee-migration dependency, which is not in buildpack.toml"))
+			})
+
+			when("Jakarta migration", func() {
+
+				tomcat10 := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse("10.0.0")}}
+
+				it.Before(func() {
+					f.AddDependency("tomcat-jakartaee-migration", filepath.Join("testdata", "stub-tomcat-jakartaee-migration.jar"))
+				})
+
+				it("does not migrate without $BP_TOMCAT_JAKARTA_MIGRATION", func() {
+					b, _, err := base.NewBase(f.Build, tomcat10, internal.Javax)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					g.Expect(f.Runner.Commands).To(gomega.BeEmpty())
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "webapps", "ROOT")).To(test.BeASymlink(f.Build.Application.Root))
+				})
+
+				it("does not migrate Jakarta namespace", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "true")()
+
+					b, _, err := base.NewBase(f.Build, tomcat10, internal.Jakarta)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					g.Expect(f.Runner.Commands).To(gomega.BeEmpty())
+				})
+
+				it("does not migrate for Tomcat 9", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "true")()
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.Javax)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					g.Expect(f.Runner.Commands).To(gomega.BeEmpty())
+				})
+
+				it("migrates unknown namespace with $BP_TOMCAT_JAKARTA_MIGRATION", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "true")()
+
+					b, _, err := base.NewBase(f.Build, tomcat10, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					g.Expect(f.Runner.Commands).To(gomega.HaveLen(1))
+				})
+
+				it("migrates Javax namespace with $BP_TOMCAT_JAKARTA_MIGRATION", func() {
+					defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "true")()
+
+					b, _, err := base.NewBase(f.Build, tomcat10, internal.Javax)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					migration := f.Build.Layers.Layer("jakarta-ROOT")
+					g.Expect(migration).To(test.HaveLayerMetadata(false, false, true))
+
+					g.Expect(f.Runner.Commands).To(gomega.HaveLen(1))
+					command := f.Runner.Commands[0]
+					g.Expect(command.Bin).To(gomega.Equal("java"))
+					g.Expect(command.Dir).To(gomega.Equal(f.Build.Application.Root))
+					g.Expect(command.Args).To(gomega.HaveLen(4))
+					g.Expect(command.Args[0]).To(gomega.Equal("-jar"))
+					g.Expect(filepath.Base(command.Args[1])).To(gomega.Equal("stub-tomcat-jakartaee-migration.jar"))
+					g.Expect(command.Args[2:]).To(gomega.Equal([]string{f.Build.Application.Root, migration.Root}))
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "webapps", "ROOT")).To(test.BeASymlink(migration.Root))
+				})
+			})
+
+			when("Tomcat compatibility", func() {
+
+				it.Before(func() {
+					f.AddDependencyWithDependency(buildpack.Dependency{
+						ID:      "tomcat-access-logging-support",
+						Version: buildpack.Version{Version: semver.MustParse("4.0.0")},
+						SHA256:  "test-sha256",
+						URI:     "https://localhost/stub-tomcat-access-logging-support-4.jar",
+						Stacks:  buildpack.Stacks{f.Build.Stack},
+					}, filepath.Join("testdata", "stub-tomcat-access-logging-support-4.jar"))
+					f.Build.Buildpack.Metadata[internal.TomcatCompatibility] = []map[string]interface{}{
+						{"id": "tomcat-access-logging-support", "tomcat": ">= 7.0.0, < 10.0.0", "version": "1.*"},
+					}
+				})
+
+				it("contributes compatible support", func() {
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "lib", "stub-tomcat-access-logging-support.jar")).To(gomega.BeAnExistingFile())
+					g.Expect(filepath.Join(layer.Root, "lib", "stub-tomcat-access-logging-support-4.jar")).NotTo(gomega.BeAnExistingFile())
+				})
+
+				it("contributes newest support without compatibility entries", func() {
+					f.Build.Buildpack.Metadata[internal.TomcatCompatibility] = []map[string]interface{}{}
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "lib", "stub-tomcat-access-logging-support-4.jar")).To(gomega.BeAnExistingFile())
+				})
+
+				it("contributes support from buildpack.toml for every version of Tomcat", func() {
+					var b struct {
+						Metadata struct {
+							Dependencies []struct {
+								ID      string `toml:"id"`
+								Version string `toml:"version"`
+							} `toml:"dependencies"`
+							TomcatCompatibility []map[string]interface{} `toml:"tomcat-compatibility"`
+						} `toml:"metadata"`
+					}
+					_, err := toml.DecodeFile(filepath.Join("..", "buildpack.toml"), &b)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+					f.Build.Buildpack.Metadata[internal.TomcatCompatibility] = b.Metadata.TomcatCompatibility
+					delete(f.Build.Buildpack.Metadata, buildpack.DependenciesMetadata)
+
+					versions := []string{"10.0.0", "10.1.0"}
+					for _, d := range b.Metadata.Dependencies {
+						switch d.ID {
+						case "tomcat":
+							versions = append(versions, d.Version)
+						case "tomcat-access-logging-support", "tomcat-lifecycle-support", "tomcat-logging-support":
+							f.AddDependencyWithVersion(d.ID, d.Version, filepath.Join("testdata", fmt.Sprintf("stub-%s.jar", d.ID)))
+						}
+					}
+
+					for _, v := range versions {
+						tc := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse(v)}}
+
+						_, _, err := base.NewBase(f.Build, tc, internal.UnknownNamespace)
+						g.Expect(err).NotTo(gomega.HaveOccurred(), "Tomcat %s", v)
+					}
+				})
+
+				it("fails without compatible support", func() {
+					tomcat10 := buildpack.Dependency{ID: "tomcat", Version: buildpack.Version{Version: semver.MustParse("10.0.0")}}
+
+					_, _, err := base.NewBase(f.Build, tomcat10, internal.UnknownNamespace)
+					g.Expect(err).To(gomega.MatchError("no version of tomcat-access-logging-support is compatible with Tomcat 10.0.0"))
+				})
+			})
+
 			it("contributes temporary directory", func() {
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -167,21 +988,21 @@ CLASSPATH=%s`, destination)))
 				it("fails with BP_TOMCAT_EXT_CONF_VERSION and no others", func() {
 					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_VERSION", "test-version")()
 
-					_, _, err := base.NewBase(f.Build)
+					_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 					g.Expect(err).To(gomega.MatchError("all of $BP_TOMCAT_EXT_CONF_VERSION, $BP_TOMCAT_EXT_CONF_URI, and $BP_TOMCAT_EXT_CONF_SHA256 must be set"))
 				})
 
 				it("fails with BP_TOMCAT_EXT_CONF_URI and no others", func() {
 					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_URI", "test-uri")()
 
-					_, _, err := base.NewBase(f.Build)
+					_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 					g.Expect(err).To(gomega.MatchError("all of $BP_TOMCAT_EXT_CONF_VERSION, $BP_TOMCAT_EXT_CONF_URI, and $BP_TOMCAT_EXT_CONF_SHA256 must be set"))
 				})
 
 				it("fails with BP_TOMCAT_EXT_CONF_SHA256 and no others", func() {
 					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_SHA256", "test-sha256")()
 
-					_, _, err := base.NewBase(f.Build)
+					_, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 					g.Expect(err).To(gomega.MatchError("all of $BP_TOMCAT_EXT_CONF_VERSION, $BP_TOMCAT_EXT_CONF_URI, and $BP_TOMCAT_EXT_CONF_SHA256 must be set"))
 				})
 
@@ -221,7 +1042,53 @@ CLASSPATH=%s`, destination)))
 					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_URI", d.URI)()
 					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_SHA256", d.SHA256)()
 
-					b, _, err := base.NewBase(f.Build)
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "fixture-marker")).To(gomega.BeAnExistingFile())
+				})
+
+				it("contributes env var external configuration from mirror", func() {
+					v, err := semver.NewVersion("1.0.0")
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					d := buildpack.Dependency{
+						ID:      "tomcat-external-configuration",
+						Name:    "Tomcat External Configuration",
+						Version: buildpack.Version{Version: v},
+						URI:     "https://configuration.example.com/stub-external-configuration.tar.gz",
+						SHA256:  "test-sha256",
+						Stacks:  buildpack.Stacks{f.Build.Stack},
+						Licenses: buildpack.Licenses{
+							{Type: "Proprietary"},
+						},
+					}
+
+					l := f.Build.Layers.Layer(d.SHA256)
+					if err := helper.CopyFile(filepath.Join("testdata", "stub-external-configuration.tar.gz"),
+						filepath.Join(l.Root, "stub-external-configuration.tar.gz")); err != nil {
+						t.Fatal(err)
+					}
+
+					file, err := os.OpenFile(l.Metadata, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer file.Close()
+
+					if err := toml.NewEncoder(file).Encode(map[string]interface{}{"metadata": d}); err != nil {
+						t.Fatal(err)
+					}
+
+					defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_CONFIGURATION_EXAMPLE_COM", "https://mirror.example.com")()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_VERSION", d.Version.String())()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_URI", d.URI)()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_SHA256", d.SHA256)()
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 					g.Expect(err).NotTo(gomega.HaveOccurred())
 
 					g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -267,7 +1134,7 @@ CLASSPATH=%s`, destination)))
 					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_SHA256", d.SHA256)()
 					defer test.ReplaceEnv(t, "BP_TOMCAT_EXT_CONF_STRIP", "1")()
 
-					b, _, err := base.NewBase(f.Build)
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 					g.Expect(err).NotTo(gomega.HaveOccurred())
 
 					g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -276,10 +1143,51 @@ CLASSPATH=%s`, destination)))
 					g.Expect(filepath.Join(layer.Root, "fixture-marker")).To(gomega.BeAnExistingFile())
 				})
 
+				it("binds the HTTP connector of an external server.xml to $PORT", func() {
+					f.AddDependency("tomcat-external-configuration", filepath.Join("testdata", "stub-external-configuration-with-server.tar.gz"))
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(filepath.Join(layer.Root, "conf", "server.xml")).To(test.HaveContent(`<Server port="8005" shutdown="SHUTDOWN">
+  <Service name="Catalina">
+    <!--
+    <Connector executor="tomcatThreadPool"
+               port="8080" protocol="HTTP/1.1"
+               connectionTimeout="20000"
+               redirectPort="8443" />
+    -->
+    <Connector port='${port.http}' protocol="HTTP/1.1"
+               connectionTimeout="20000"
+               redirectPort="8443" />
+    <Connector port="8009" protocol="AJP/1.3" redirectPort="8443" />
+    <Engine name="Catalina" defaultHost="localhost"/>
+  </Service>
+</Server>
+`))
+				})
+
+				it("leaves an external server.xml with more than one HTTP connector", func() {
+					f.AddDependency("tomcat-external-configuration", filepath.Join("testdata", "stub-external-configuration-with-connectors.tar.gz"))
+
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(b.Contribute()).To(gomega.Succeed())
+
+					layer := f.Build.Layers.Layer("catalina-base")
+					g.Expect(ioutil.ReadFile(filepath.Join(layer.Root, "conf", "server.xml"))).To(gomega.ContainSubstring(`
+    <Connector port="8080" protocol="HTTP/1.1"/>
+    <Connector port="8081" protocol="HTTP/1.1"/>`))
+				})
+
 				it("contributes buildpack.toml external configuration", func() {
 					f.AddDependency("tomcat-external-configuration", filepath.Join("testdata", "stub-external-configuration.tar.gz"))
 
-					b, _, err := base.NewBase(f.Build)
+					b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 					g.Expect(err).NotTo(gomega.HaveOccurred())
 
 					g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -291,7 +1199,7 @@ CLASSPATH=%s`, destination)))
 			})
 
 			it("sets CATALINA_BASE", func() {
-				b, _, err := base.NewBase(f.Build)
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
 				g.Expect(err).NotTo(gomega.HaveOccurred())
 
 				g.Expect(b.Contribute()).To(gomega.Succeed())
@@ -300,6 +1208,37 @@ CLASSPATH=%s`, destination)))
 				g.Expect(layer).To(test.HaveLayerMetadata(false, false, true))
 				g.Expect(layer).To(test.HaveOverrideLaunchEnvironment("CATALINA_BASE", layer.Root))
 			})
+
+			it("contributes identical layers when rebuilt", func() {
+				defer test.ReplaceEnv(t, "SOURCE_DATE_EPOCH", "1577836800")()
+
+				b, _, err := base.NewBase(f.Build, tomcat, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				digest := func() map[string]map[string]string {
+					d := make(map[string]map[string]string)
+					for _, n := range []string{"catalina-base", "catalina-base-conf", "catalina-base-lib"} {
+						d[n] = itest.Digest(t, f.Build.Layers.Layer(n).Root)
+					}
+					return d
+				}
+
+				first := digest()
+				g.Expect(first["catalina-base"]).To(gomega.HaveKeyWithValue("/exec.d/port", gomega.HavePrefix("L")))
+				g.Expect(first["catalina-base"]).To(gomega.HaveKeyWithValue("/temp", gomega.HavePrefix("drwx------")))
+				g.Expect(first["catalina-base-conf"]).To(gomega.HaveKeyWithValue("/conf/server.xml",
+					gomega.ContainSubstring("2020-01-01 00:00:00 +0000 UTC")))
+				g.Expect(first["catalina-base-lib"]).To(gomega.HaveKeyWithValue("/bin/setenv.sh", gomega.HavePrefix("-rwxr-xr-x")))
+
+				for n := range first {
+					g.Expect(os.RemoveAll(f.Build.Layers.Layer(n).Metadata)).To(gomega.Succeed())
+				}
+				g.Expect(b.Contribute()).To(gomega.Succeed())
+
+				g.Expect(digest()).To(gomega.Equal(first))
+			})
 		})
 	}, spec.Report(report.Terminal{}))
 }
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+)
+
+// compatibleDependency returns the best version of a dependency that is compatible with a version of Tomcat.  If the
+// "tomcat-compatibility" buildpack metadata has no entries for the dependency, any version is compatible.
+func compatibleDependency(build build.Build, deps buildpack.Dependencies, id string, tomcat buildpack.Dependency) (buildpack.Dependency, error) {
+	entries, ok := build.Buildpack.Metadata[internal.TomcatCompatibility].([]map[string]interface{})
+	if !ok {
+		return deps.Best(id, "", build.Stack)
+	}
+
+	mapped := false
+	for _, e := range entries {
+		if e["id"] != id {
+			continue
+		}
+		mapped = true
+
+		t, ok := e["tomcat"].(string)
+		if !ok {
+			return buildpack.Dependency{}, fmt.Errorf("%s entry for %s does not have a tomcat version", internal.TomcatCompatibility, id)
+		}
+
+		c, err := semver.NewConstraint(t)
+		if err != nil {
+			return buildpack.Dependency{}, fmt.Errorf("%s entry for %s has an invalid tomcat version: %w", internal.TomcatCompatibility, id, err)
+		}
+
+		if !c.Check(tomcat.Version.Version) {
+			continue
+		}
+
+		v, ok := e["version"].(string)
+		if !ok {
+			return buildpack.Dependency{}, fmt.Errorf("%s entry for %s does not have a version", internal.TomcatCompatibility, id)
+		}
+
+		d, err := deps.Best(id, v, build.Stack)
+		if err != nil {
+			return buildpack.Dependency{}, fmt.Errorf("no version of %s is compatible with Tomcat %s: %w", id, tomcat.Version.Original(), err)
+		}
+
+		return d, nil
+	}
+
+	if !mapped {
+		return deps.Best(id, "", build.Stack)
+	}
+
+	return buildpack.Dependency{}, fmt.Errorf("no version of %s is compatible with Tomcat %s", id, tomcat.Version.Original())
+}
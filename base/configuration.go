@@ -0,0 +1,189 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+)
+
+// ConfigurationFiles are the files copied from the buildpack root to the Tomcat configuration.
+var ConfigurationFiles = []string{"context.xml", "logging.properties", "server.xml", "web.xml"}
+
+// contributeConfiguration contributes the Tomcat configuration to its own layer.  Configuration files in the external
+// configuration replace those from the buildpack, and are then configured for TLS and DataSources.
+func (b Base) contributeConfiguration() error {
+	inputs := internal.NewInputs()
+	for _, f := range ConfigurationFiles {
+		if err := inputs.AddFile(filepath.Join(b.buildpack.Root, f)); err != nil {
+			return err
+		}
+	}
+	inputs.AddEnvironment("BP_TOMCAT_EXT_CONF_STRIP", "BP_TOMCAT_HTTPS_REDIRECT")
+	if b.hasExternalConfiguration() {
+		inputs.AddDependency(b.externalConfiguration.Dependency)
+	}
+
+	m := configurationMarker{b.tls, b.dataSources, inputs}
+	return internal.Contribute(b.configurationLayer, m, inputs, func(layer layers.Layer) error {
+		if err := os.RemoveAll(layer.Root); err != nil {
+			return err
+		}
+
+		for _, f := range ConfigurationFiles {
+			layer.Logger.Body("Copying %s to %s/conf", f, layer.Root)
+			if err := helper.CopyFile(filepath.Join(b.buildpack.Root, f), filepath.Join(layer.Root, "conf", f)); err != nil {
+				return err
+			}
+		}
+
+		if b.hasExternalConfiguration() {
+			conf := filepath.Join(b.externalLayer.Root, "conf")
+
+			if ok, err := helper.FileExists(conf); err != nil {
+				return err
+			} else if ok {
+				layer.Logger.Body("Copying external configuration to %s/conf", layer.Root)
+				if err := helper.CopyDirectory(conf, filepath.Join(layer.Root, "conf")); err != nil {
+					return err
+				}
+			}
+
+			if ok, err := helper.FileExists(filepath.Join(conf, "server.xml")); err != nil {
+				return err
+			} else if ok {
+				if err := configurePort(layer); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := b.configureTLS(layer); err != nil {
+			return err
+		}
+
+		if err := b.configureDataSources(layer); err != nil {
+			return err
+		}
+
+		return internal.Normalize(layer.Root)
+	}, layers.Cache, layers.Launch)
+}
+
+// configurePort binds the HTTP connector of a server.xml from the external configuration to the port set from $PORT at
+// launch.  A server.xml without exactly one HTTP connector is left as it is, with a warning, unless one of its
+// connectors already listens on that port.
+func configurePort(layer layers.Layer) error {
+	file := filepath.Join(layer.Root, "conf", "server.xml")
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	s := string(b)
+
+	connectors := httpConnectors(s)
+	for _, c := range connectors {
+		if attribute(s[c[0]:c[1]], "port") == HTTPPort {
+			return nil
+		}
+	}
+
+	if len(connectors) != 1 {
+		layer.Logger.BodyWarning("External server.xml has %d HTTP connectors and none with port %s, so $PORT is ignored",
+			len(connectors), HTTPPort)
+		return nil
+	}
+
+	layer.Logger.Body("Binding HTTP connector in external server.xml to $PORT")
+	return updateHTTPConnectors(file, func(tag string) string {
+		return setAttribute(tag, "port", HTTPPort)
+	})
+}
+
+// contributeExternalConfiguration expands the external configuration into its own layer.
+func (b Base) contributeExternalConfiguration() error {
+	if !b.hasExternalConfiguration() {
+		return nil
+	}
+
+	return internal.Contribute(b.externalLayer, b.externalConfiguration, b.externalConfiguration.Inputs, func(layer layers.Layer) error {
+		if err := os.RemoveAll(layer.Root); err != nil {
+			return err
+		}
+
+		artifact, err := b.externalConfigurationLayer.Artifact()
+		if err != nil {
+			return err
+		}
+
+		layer.Logger.Body("Expanding to %s", layer.Root)
+		if err := helper.ExtractTarGz(artifact, layer.Root, b.externalConfiguration.Strip); err != nil {
+			return err
+		}
+
+		return internal.Normalize(layer.Root)
+	}, layers.Cache, layers.Launch)
+}
+
+func (b Base) hasExternalConfiguration() bool {
+	return !reflect.DeepEqual(b.externalConfigurationLayer, layers.DownloadLayer{})
+}
+
+type configurationMarker struct {
+	TLS         tlsConfiguration        `toml:"tls"`
+	DataSources dataSourceConfiguration `toml:"data-sources"`
+	Inputs      internal.Inputs         `toml:"inputs"`
+}
+
+func (configurationMarker) Identity() (string, string) {
+	return "Apache Tomcat Configuration", ""
+}
+
+type externalConfigurationMarker struct {
+	Dependency buildpack.Dependency `toml:"dependency"`
+	Strip      int                  `toml:"strip"`
+	Inputs     internal.Inputs      `toml:"inputs"`
+}
+
+func (m externalConfigurationMarker) Identity() (string, string) {
+	return m.Dependency.Name, m.Dependency.Version.Original()
+}
+
+// externalConfigurationStrip returns the number of directory levels to strip from the external configuration.
+func externalConfigurationStrip() (int, error) {
+	s, ok := os.LookupEnv("BP_TOMCAT_EXT_CONF_STRIP")
+	if !ok {
+		return 0, nil
+	}
+
+	c, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("$BP_TOMCAT_EXT_CONF_STRIP must be an integer: %w", err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// HTTPPort is the value of the port attribute of an HTTP connector that listens on the port set from $PORT at launch.
+const HTTPPort = "${port.http}"
+
+var (
+	commentPattern   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	connectorPattern = regexp.MustCompile(`<Connector\b[^>]*>`)
+)
+
+// httpConnectors returns the locations of the start tags of the HTTP connectors in a server.xml, those that are not
+// commented out and are neither HTTPS nor AJP connectors.
+func httpConnectors(s string) [][]int {
+	var connectors [][]int
+	for _, c := range tags(s, connectorPattern) {
+		tag := s[c[0]:c[1]]
+		if strings.EqualFold(attribute(tag, "SSLEnabled"), "true") ||
+			strings.Contains(strings.ToUpper(attribute(tag, "protocol")), "AJP") {
+			continue
+		}
+
+		connectors = append(connectors, c)
+	}
+
+	return connectors
+}
+
+// tags returns the locations of the start tags matching a pattern in a configuration file that are not commented out.
+func tags(s string, pattern *regexp.Regexp) [][]int {
+	comments := commentPattern.FindAllStringIndex(s, -1)
+
+	var tags [][]int
+	for _, t := range pattern.FindAllStringIndex(s, -1) {
+		commented := false
+		for _, r := range comments {
+			if t[0] >= r[0] && t[1] <= r[1] {
+				commented = true
+				break
+			}
+		}
+
+		if !commented {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// attribute returns the value of an attribute of a start tag, or "" if the tag does not have the attribute.
+func attribute(tag string, name string) string {
+	if m := attributePattern(name).FindStringSubmatch(tag); m != nil {
+		return m[2]
+	}
+
+	return ""
+}
+
+// setAttribute sets the value of an attribute of a start tag, adding the attribute if the tag does not have it.
+func setAttribute(tag string, name string, value string) string {
+	p := attributePattern(name)
+	if p.MatchString(tag) {
+		return p.ReplaceAllLiteralString(tag, fmt.Sprintf("%s='%s'", name, value))
+	}
+
+	end := strings.TrimSuffix(strings.TrimSuffix(tag, ">"), "/")
+	return fmt.Sprintf("%s %s='%s'%s", strings.TrimRight(end, " \t\n"), name, value, tag[len(end):])
+}
+
+func attributePattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`\b%s\s*=\s*(['"])([^'"]*)['"]`, regexp.QuoteMeta(name)))
+}
+
+// updateHTTPConnectors replaces the start tag of each HTTP connector in a server.xml with the result of a function.
+func updateHTTPConnectors(file string, f func(tag string) string) error {
+	i, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	s := string(b)
+
+	connectors := httpConnectors(s)
+	for j := len(connectors) - 1; j >= 0; j-- {
+		c := connectors[j]
+		s = s[:c[0]] + f(s[c[0]:c[1]]) + s[c[1]:]
+	}
+
+	return ioutil.WriteFile(file, []byte(s), i.Mode())
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+)
+
+// propertiesFile is the shell expression for the catalina.properties file that credentials are written to at launch.
+const propertiesFile = "${TMPDIR:-/tmp}/tomcat/catalina.properties"
+
+type dataSource struct {
+	Name            string `toml:"name"`
+	DriverClassName string `toml:"driver-class-name"`
+}
+
+type dataSourceConfiguration struct {
+	DataSources []dataSource `toml:"data-sources"`
+
+	// MaxTotal and MaxWait are the names of the pool attributes, which differ between the DBCP 1 of Tomcat 7 and the
+	// DBCP 2 of later versions.
+	MaxTotal string `toml:"max-total"`
+	MaxWait  string `toml:"max-wait"`
+}
+
+// Enabled returns whether any database services are bound to the application.
+func (d dataSourceConfiguration) Enabled() bool {
+	return len(d.DataSources) > 0
+}
+
+func (b Base) contributeDataSources(layer layers.Layer) error {
+	if !b.dataSources.Enabled() {
+		return nil
+	}
+
+	layer.Logger.Header("Contributing DataSources")
+
+	for _, d := range b.dataSources.DataSources {
+		layer.Logger.Body("Configuring %s", d.Name)
+	}
+
+	return b.contributeLaunchConfiguration(layer, "datasources", `%s datasources "%s"
+export JAVA_OPTS="${JAVA_OPTS} -Dcatalina.config=file:%s"
+`, Helper, propertiesFile, propertiesFile)
+}
+
+// configureDataSources adds a DataSource resource for each bound database service to the context.xml in the
+// configuration layer.  The connection and pool attributes are placeholders for properties that are written at launch.
+func (b Base) configureDataSources(layer layers.Layer) error {
+	if !b.dataSources.Enabled() {
+		return nil
+	}
+
+	var s strings.Builder
+	for _, d := range b.dataSources.DataSources {
+		s.WriteString(fmt.Sprintf(`    <Resource name="%s" auth="Container" type="javax.sql.DataSource"
+              driverClassName="%s" url="${%s}" username="${%s}" password="${%s}"
+              %s="${%s}" maxIdle="${%s}" %s="${%s}"/>
+`, escape(d.Name), escape(d.DriverClassName), escape(launch.DataSourceProperty(d.Name, "url")),
+			escape(launch.DataSourceProperty(d.Name, "username")), escape(launch.DataSourceProperty(d.Name, "password")),
+			b.dataSources.MaxTotal, launch.MaxTotalProperty, launch.MaxIdleProperty,
+			b.dataSources.MaxWait, launch.MaxWaitMillisProperty))
+	}
+
+	return internal.InsertConfiguration(filepath.Join(layer.Root, "conf", "context.xml"), "datasources", "</Context>",
+		s.String())
+}
+
+// newDataSourceConfiguration returns the DataSources to generate for the database services bound to the application.
+// The JDBC driver of each database is expected to be packaged with the application.
+func newDataSourceConfiguration(build build.Build, tomcat buildpack.Dependency) dataSourceConfiguration {
+	c := dataSourceConfiguration{MaxTotal: "maxTotal", MaxWait: "maxWaitMillis"}
+
+	for _, s := range build.Services.Services {
+		database, ok := launch.DatabaseFor(s)
+		if !ok {
+			continue
+		}
+
+		c.DataSources = append(c.DataSources, dataSource{launch.DataSourceName(s), database.DriverClassName})
+	}
+
+	if tomcat.Version.Major() < 8 {
+		c.MaxTotal, c.MaxWait = "maxActive", "maxWait"
+	}
+
+	sort.Slice(c.DataSources, func(i int, j int) bool {
+		return c.DataSources[i].Name < c.DataSources[j].Name
+	})
+
+	return c
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+)
+
+// layerConfiguration are the directories of a layer that configure the layer itself rather than being part of
+// CATALINA_BASE.
+var layerConfiguration = []string{"env", "env.build", "env.launch", "exec.d", "profile.d"}
+
+type link struct {
+	Path   string `toml:"path"`
+	Target string `toml:"target"`
+}
+
+// join returns a link for each file in the roots, so that the layers that CATALINA_BASE is split into appear as a
+// single directory at launch.  A file in a later root replaces the same file in an earlier one.
+func join(roots ...string) ([]link, error) {
+	targets := make(map[string]string)
+
+	for _, r := range roots {
+		if err := filepath.Walk(r, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) && path == r {
+				return filepath.SkipDir
+			} else if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(r, path)
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				if filepath.Dir(rel) == "." && contains(layerConfiguration, rel) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			targets[rel] = path
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var links []link
+	for p, t := range targets {
+		links = append(links, link{p, t})
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Path < links[j].Path
+	})
+
+	return links, nil
+}
+
+func (Base) contributeLinks(layer layers.Layer, links []link) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	layer.Logger.Header("Linking configuration and libraries to %s", layer.Root)
+
+	for _, l := range links {
+		if err := helper.WriteSymlink(l.Target, filepath.Join(layer.Root, l.Path)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
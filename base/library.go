@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+)
+
+// contributeLibraries contributes the support jars to their own layer, so that it is only contributed again when one
+// of those dependencies changes.
+func (b Base) contributeLibraries() error {
+	m := b.libraryMarker()
+
+	return internal.Contribute(b.libraryLayer, m, m.Inputs, func(layer layers.Layer) error {
+		if err := os.RemoveAll(layer.Root); err != nil {
+			return err
+		}
+
+		for _, l := range []layers.DownloadLayer{b.accessLoggingLayer, b.lifecycleLayer} {
+			if err := b.copyLibrary(layer, l); err != nil {
+				return err
+			}
+		}
+
+		if err := b.contributeLoggingSupport(layer); err != nil {
+			return err
+		}
+
+		return internal.Normalize(layer.Root)
+	}, layers.Cache, layers.Launch)
+}
+
+func (Base) copyLibrary(layer layers.Layer, dependency layers.DownloadLayer) error {
+	artifact, err := dependency.Artifact()
+	if err != nil {
+		return err
+	}
+
+	layer.Logger.Body("Copying %s to %s/lib", filepath.Base(artifact), layer.Root)
+	return helper.CopyFile(artifact, filepath.Join(layer.Root, "lib", filepath.Base(artifact)))
+}
+
+func (b Base) contributeLoggingSupport(layer layers.Layer) error {
+	artifact, err := b.loggingLayer.Artifact()
+	if err != nil {
+		return err
+	}
+
+	destination := filepath.Join(layer.Root, "bin", filepath.Base(artifact))
+
+	layer.Logger.Body("Copying %s to %s/bin", filepath.Base(artifact), layer.Root)
+	if err := helper.CopyFile(artifact, destination); err != nil {
+		return err
+	}
+
+	layer.Logger.Body("Writing %s/bin/setenv.sh", layer.Root)
+	return helper.WriteFile(filepath.Join(layer.Root, "bin", "setenv.sh"), 0755, `#!/bin/sh
+
+CLASSPATH=%s`, destination)
+}
+
+func (b Base) libraryMarker() libraryMarker {
+	inputs := internal.NewInputs()
+	for _, d := range b.libraries {
+		inputs.AddDependency(d)
+	}
+
+	return libraryMarker{b.libraries, inputs}
+}
+
+type libraryMarker struct {
+	Dependencies []buildpack.Dependency `toml:"dependencies"`
+	Inputs       internal.Inputs        `toml:"inputs"`
+}
+
+func (m libraryMarker) Identity() (string, string) {
+	return "Apache Tomcat Support", m.Dependencies[0].Version.Original()
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/runner"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+)
+
+// Migration is a web application that is migrated from the Javax to the Jakarta namespace into its own launch layer.
+type Migration struct {
+	// Source is the location of the web application, either an exploded web application or a WAR file.
+	Source string
+
+	// SHA256 is the hash of the web application.
+	SHA256 string
+
+	layer     layers.Layer
+	runner    runner.Runner
+	split     bool
+	tool      buildpack.Dependency
+	toolLayer layers.DownloadLayer
+}
+
+// Contribute migrates the web application into its layer, returning whether the layer was reused.
+func (m Migration) Contribute() (bool, error) {
+	reused, err := m.layer.MetadataMatches(m.marker())
+	if err != nil {
+		return false, err
+	}
+
+	return reused, m.layer.Contribute(m.marker(), func(layer layers.Layer) error {
+		if err := os.RemoveAll(layer.Root); err != nil {
+			return err
+		}
+
+		artifact, err := m.toolLayer.Artifact()
+		if err != nil {
+			return err
+		}
+
+		layer.Logger.Body("Migrating %s to the Jakarta namespace in %s", filepath.Base(m.Source), layer.Root)
+
+		if i, err := os.Stat(m.Source); err != nil {
+			return err
+		} else if i.IsDir() {
+			return m.runner.Run("java", m.Source, "-jar", artifact, m.Source, layer.Root)
+		}
+
+		t, err := ioutil.TempDir("", "jakarta-migration")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(t)
+
+		war := filepath.Join(t, filepath.Base(m.Source))
+		if err := m.runner.Run("java", filepath.Dir(m.Source), "-jar", artifact, m.Source, war); err != nil {
+			return err
+		}
+
+		return helper.ExtractZip(war, layer.Root, 0)
+	}, layers.Launch)
+}
+
+// Root returns the location that the web application is migrated to.
+func (m Migration) Root() string {
+	return m.layer.Root
+}
+
+func (m Migration) marker() migrationMarker {
+	return migrationMarker{filepath.Base(m.Source), m.SHA256, m.tool.SHA256, m.split}
+}
+
+type migrationMarker struct {
+	Name           string `toml:"name"`
+	SHA256         string `toml:"sha256"`
+	Tool           string `toml:"tool"`
+	SplitLibraries bool   `toml:"split-libraries,omitempty"`
+}
+
+func (m migrationMarker) Identity() (string, string) {
+	return "Jakarta Migration", m.Name
+}
+
+// NewMigration creates a new Migration instance.  The layer the web application is migrated to is unique to the context
+// path it is mounted at.  Split is whether the libraries of the migrated web application are moved into their own
+// layer.
+func NewMigration(build build.Build, source string, contextPath string, tool buildpack.Dependency, split bool) (Migration, error) {
+	i, err := os.Stat(source)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	hash := sha256File
+	if i.IsDir() {
+		hash = sha256Directory
+	}
+
+	s, err := hash(source)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	return Migration{
+		source,
+		s,
+		build.Layers.Layer(fmt.Sprintf("jakarta-%s", contextPath)),
+		build.Runner,
+		split,
+		tool,
+		build.Layers.DownloadLayer(tool),
+	}, nil
+}
+
+// jakartaMigration returns whether web applications should be migrated from the Javax to the Jakarta namespace, warning
+// when the namespace of the web applications is not supported by the version of Tomcat.  Web applications whose
+// namespace is unknown, because they reference neither namespace or were not scanned, are migrated if
+// $BP_TOMCAT_JAKARTA_MIGRATION is set, as the migration leaves classes in the Jakarta namespace unchanged.
+func jakartaMigration(build build.Build, tomcat buildpack.Dependency, namespace internal.Namespace) (bool, error) {
+	jakarta := tomcat.Version.Major() >= 10
+
+	if namespace == internal.Jakarta && !jakarta {
+		build.Logger.HeaderWarning("Web applications use the jakarta.servlet namespace which Tomcat %s does not support",
+			tomcat.Version.Original())
+		return false, nil
+	}
+
+	if namespace == internal.Jakarta || !jakarta {
+		return false, nil
+	}
+
+	m, err := internal.JakartaMigration()
+	if err != nil {
+		return false, err
+	}
+
+	if !m && namespace == internal.Javax {
+		build.Logger.HeaderWarning("Web applications use the javax.servlet namespace which Tomcat %s does not support, set $BP_TOMCAT_JAKARTA_MIGRATION to migrate them",
+			tomcat.Version.Original())
+	}
+
+	return m, nil
+}
+
+// migrations returns the web applications to mount in CATALINA_BASE and the Migrations that must be contributed for them.
+func migrations(build build.Build, sources []source, tool buildpack.Dependency, split bool) ([]webApplication, []Migration, error) {
+	var (
+		applications []webApplication
+		migrations   []Migration
+	)
+
+	for _, s := range sources {
+		m, err := NewMigration(build, s.path, s.contextPath, tool, split)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		applications = append(applications, webApplication{ContextPath: s.contextPath, Source: m.Root(), SHA256: m.SHA256})
+		migrations = append(migrations, m)
+	}
+
+	return applications, migrations, nil
+}
+
+func sha256Directory(root string) (string, error) {
+	s := sha256.New()
+
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(s, "%s\x00%s\x00", rel, info.Mode()); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(s, f)
+		return err
+	}); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(s.Sum(nil)), nil
+}
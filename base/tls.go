@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Masterminds/semver"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+)
+
+// tlsDirectory is the shell expression for the directory that the certificate and private key are written to at launch.
+const tlsDirectory = "${TMPDIR:-/tmp}/tomcat/tls"
+
+type tlsConfiguration struct {
+	Enabled  bool `toml:"enabled"`
+	Chain    bool `toml:"chain"`
+	Redirect bool `toml:"redirect"`
+}
+
+func (b Base) contributeTLS(layer layers.Layer) error {
+	if !b.tls.Enabled {
+		return nil
+	}
+
+	layer.Logger.Header("Contributing HTTPS Connector")
+	layer.Logger.LaunchConfiguration("Set $BPL_TOMCAT_HTTPS_PORT to configure the HTTPS port", "8443")
+
+	return b.contributeLaunchConfiguration(layer, "tls", `%s tls "%s"
+
+export JAVA_OPTS="${JAVA_OPTS} -Dport.https=${BPL_TOMCAT_HTTPS_PORT:-8443} -D%s=%s"
+`, Helper, tlsDirectory, launch.TLSDirectoryProperty, tlsDirectory)
+}
+
+// configureTLS adds the HTTPS connector, and optionally the HTTPS redirect, to the Tomcat configuration.  The HTTP
+// connectors redirect to the HTTPS connector only if the HTTPS redirect is configured.  The connector reads the
+// certificate and private key from the directory they are written to at launch.
+func (b Base) configureTLS(layer layers.Layer) error {
+	if !b.tls.Enabled {
+		return nil
+	}
+
+	tls := fmt.Sprintf("${%s}", launch.TLSDirectoryProperty)
+
+	chain := ""
+	if b.tls.Chain {
+		chain = fmt.Sprintf(`
+                             certificateChainFile='%s'`, filepath.Join(tls, launch.CertificateChainFile))
+	}
+
+	layer.Logger.Body("Adding HTTPS connector to %s/conf/server.xml", layer.Root)
+	if err := internal.InsertConfiguration(filepath.Join(layer.Root, "conf", "server.xml"), "https-connector", "<Engine",
+		fmt.Sprintf(`        <Connector port='${port.https}' SSLEnabled='true' scheme='https' secure='true' bindOnInit='false' connectionTimeout='20000'>
+            <SSLHostConfig>
+                <Certificate certificateFile='%s'
+                             certificateKeyFile='%s'%s/>
+            </SSLHostConfig>
+        </Connector>`, filepath.Join(tls, launch.CertificateFile), filepath.Join(tls, launch.PrivateKeyFile), chain)); err != nil {
+		return err
+	}
+
+	if b.tls.Redirect {
+		layer.Logger.Body("Redirecting HTTP connectors in %s/conf/server.xml to the HTTPS connector", layer.Root)
+		if err := updateHTTPConnectors(filepath.Join(layer.Root, "conf", "server.xml"), func(tag string) string {
+			return setAttribute(tag, "redirectPort", "${port.https}")
+		}); err != nil {
+			return err
+		}
+
+		layer.Logger.Body("Adding HTTPS redirect to %s/conf/web.xml", layer.Root)
+		if err := internal.InsertConfiguration(filepath.Join(layer.Root, "conf", "web.xml"), "https-redirect", "</web-app>",
+			`    <security-constraint>
+        <web-resource-collection>
+            <web-resource-name>HTTPS Redirect</web-resource-name>
+            <url-pattern>/*</url-pattern>
+        </web-resource-collection>
+        <user-data-constraint>
+            <transport-guarantee>CONFIDENTIAL</transport-guarantee>
+        </user-data-constraint>
+    </security-constraint>`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newTLSConfiguration returns the HTTPS connector configuration for a bound TLS service.  The connector is configured
+// with an SSLHostConfig, which Tomcat 7 and 8.0 do not support.
+func newTLSConfiguration(build build.Build, tomcat buildpack.Dependency) (tlsConfiguration, error) {
+	if !build.Services.HasService(launch.TLSService, launch.CertificateCredential, launch.PrivateKeyCredential) {
+		return tlsConfiguration{}, nil
+	}
+
+	if tomcat.Version.LessThan(semver.MustParse("8.5.0")) {
+		return tlsConfiguration{}, fmt.Errorf("a %s service binding requires Tomcat 8.5 or later, but Tomcat %s is used",
+			launch.TLSService, tomcat.Version.Original())
+	}
+
+	redirect := false
+	if s, ok := os.LookupEnv("BP_TOMCAT_HTTPS_REDIRECT"); ok {
+		r, err := strconv.ParseBool(s)
+		if err != nil {
+			return tlsConfiguration{}, fmt.Errorf("$BP_TOMCAT_HTTPS_REDIRECT must be a boolean: %w", err)
+		}
+		redirect = r
+	}
+
+	return tlsConfiguration{
+		Enabled: true,
+		Chain: build.Services.HasService(launch.TLSService,
+			launch.CertificateCredential, launch.CertificateChainCredential, launch.PrivateKeyCredential),
+		Redirect: redirect,
+	}, nil
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+)
+
+// WAR is an unexploded web application archive that is expanded into its own launch layer.
+type WAR struct {
+	// Path is the location of the archive.
+	Path string
+
+	// SHA256 is the hash of the archive.
+	SHA256 string
+
+	layer layers.Layer
+	split bool
+}
+
+// Contribute expands the archive into its layer, returning whether the layer was reused.
+func (w WAR) Contribute() (bool, error) {
+	reused, err := w.layer.MetadataMatches(w.marker())
+	if err != nil {
+		return false, err
+	}
+
+	return reused, w.layer.Contribute(w.marker(), func(layer layers.Layer) error {
+		if err := os.RemoveAll(layer.Root); err != nil {
+			return err
+		}
+
+		layer.Logger.Body("Expanding %s to %s", filepath.Base(w.Path), layer.Root)
+		return helper.ExtractZip(w.Path, layer.Root, 0)
+	}, layers.Launch)
+}
+
+// Root returns the location that the archive is expanded to.
+func (w WAR) Root() string {
+	return w.layer.Root
+}
+
+func (w WAR) marker() warMarker {
+	return warMarker{filepath.Base(w.Path), w.SHA256, w.split}
+}
+
+// warMarker records whether the libraries of the expanded archive were moved into their own layer, so that it is
+// expanded again if that changes.
+type warMarker struct {
+	Name           string `toml:"name"`
+	SHA256         string `toml:"sha256"`
+	SplitLibraries bool   `toml:"split-libraries,omitempty"`
+}
+
+func (m warMarker) Identity() (string, string) {
+	return "Web Application Archive", m.Name
+}
+
+// NewWAR creates a new WAR instance.  The layer the archive is expanded to is unique to the context path it is mounted
+// at.  Split is whether the libraries of the expanded archive are moved into their own layer.
+func NewWAR(path string, contextPath string, layers layers.Layers, split bool) (WAR, error) {
+	s, err := sha256File(path)
+	if err != nil {
+		return WAR{}, err
+	}
+
+	return WAR{path, s, layers.Layer(fmt.Sprintf("war-%s", contextPath)), split}, nil
+}
+
+func findWAR(root string, exclude []string) (string, bool, error) {
+	if w, ok := os.LookupEnv("BP_TOMCAT_WAR"); ok {
+		path := filepath.Join(root, w)
+		if rel, err := filepath.Rel(root, path); err != nil {
+			return "", false, err
+		} else if rel == ".." || strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator)) {
+			return "", false, fmt.Errorf("$BP_TOMCAT_WAR %s is outside of the application root", w)
+		}
+
+		if ok, err := helper.FileExists(path); err != nil {
+			return "", false, err
+		} else if !ok {
+			return "", false, fmt.Errorf("$BP_TOMCAT_WAR %s does not exist", w)
+		}
+
+		return path, true, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(root, "*.war"))
+	if err != nil {
+		return "", false, err
+	}
+
+	var candidates []string
+	for _, m := range matches {
+		if !contains(exclude, m) {
+			candidates = append(candidates, m)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return candidates[0], true, nil
+	default:
+		return "", false, fmt.Errorf("multiple WAR files found in application root, set $BP_TOMCAT_WAR to select one")
+	}
+}
+
+func contains(candidates []string, value string) bool {
+	for _, c := range candidates {
+		if c == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	s := sha256.New()
+	if _, err := io.Copy(s, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(s.Sum(nil)), nil
+}
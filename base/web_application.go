@@ -0,0 +1,221 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+)
+
+type webApplication struct {
+	ContextPath string `toml:"context-path"`
+	Source      string `toml:"source"`
+	SHA256      string `toml:"sha256,omitempty"`
+	Libraries   string `toml:"libraries,omitempty"`
+}
+
+type source struct {
+	contextPath string
+	path        string
+}
+
+// HasWebApplications returns whether an application contains any web applications, either a primary web application or
+// additional ones configured with $BP_TOMCAT_CONTEXT_PATHS.
+func HasWebApplications(root string) (bool, error) {
+	sources, err := webApplicationSources(root)
+	if err != nil {
+		return false, err
+	}
+
+	return len(sources) > 0, nil
+}
+
+// ServletNamespace returns the namespace of the Servlet API that the web applications in an application are compiled
+// against.
+func ServletNamespace(root string) (internal.Namespace, error) {
+	sources, err := webApplicationSources(root)
+	if err != nil {
+		return internal.UnknownNamespace, err
+	}
+
+	var paths []string
+	for _, s := range sources {
+		paths = append(paths, s.path)
+	}
+
+	return internal.ServletNamespace(paths...)
+}
+
+// webApplications returns the web applications to mount in CATALINA_BASE and the WARs that must be expanded for them.
+func webApplications(sources []source, layers layers.Layers, split bool) ([]webApplication, []WAR, error) {
+	var (
+		applications []webApplication
+		wars         []WAR
+	)
+
+	for _, s := range sources {
+		if i, err := os.Stat(s.path); err != nil {
+			return nil, nil, err
+		} else if i.IsDir() {
+			applications = append(applications, webApplication{ContextPath: s.contextPath, Source: s.path})
+			continue
+		}
+
+		w, err := NewWAR(s.path, s.contextPath, layers, split)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		applications = append(applications, webApplication{ContextPath: s.contextPath, Source: w.Root(), SHA256: w.SHA256})
+		wars = append(wars, w)
+	}
+
+	return applications, wars, nil
+}
+
+// webApplicationSources returns the location and context path of each web application.  The primary application is
+// mounted at $BP_TOMCAT_CONTEXT_PATH and any additional applications are mounted at the context paths in
+// $BP_TOMCAT_CONTEXT_PATHS.
+func webApplicationSources(root string) ([]source, error) {
+	additional, err := additionalWebApplications(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var exclude []string
+	for _, path := range additional {
+		exclude = append(exclude, path)
+	}
+
+	var (
+		sources []source
+		paths   = make(map[string]string)
+	)
+
+	add := func(contextPath string, path string) error {
+		if p, ok := paths[contextPath]; ok {
+			return fmt.Errorf("both %s and %s are mounted at context path %s", p, path, contextPath)
+		}
+		paths[contextPath] = path
+
+		sources = append(sources, source{contextPath, path})
+		return nil
+	}
+
+	if path, ok, err := primaryWebApplication(root, exclude); err != nil {
+		return nil, err
+	} else if ok {
+		if err := add(contextPath(), path); err != nil {
+			return nil, err
+		}
+	}
+
+	var contextPaths []string
+	for cp := range additional {
+		contextPaths = append(contextPaths, cp)
+	}
+	sort.Strings(contextPaths)
+
+	for _, cp := range contextPaths {
+		if err := add(cp, additional[cp]); err != nil {
+			return nil, err
+		}
+	}
+
+	return sources, nil
+}
+
+func additionalWebApplications(root string) (map[string]string, error) {
+	s, ok := os.LookupEnv("BP_TOMCAT_CONTEXT_PATHS")
+	if !ok {
+		return nil, nil
+	}
+
+	additional := make(map[string]string)
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("$BP_TOMCAT_CONTEXT_PATHS entry %s must be of the form <context-path>=<path>", entry)
+		}
+
+		cp := normalizeContextPath(strings.TrimSpace(parts[0]))
+		if _, ok := additional[cp]; ok {
+			return nil, fmt.Errorf("$BP_TOMCAT_CONTEXT_PATHS contains context path %s more than once", cp)
+		}
+
+		path := filepath.Join(root, strings.TrimSpace(parts[1]))
+		if rel, err := filepath.Rel(root, path); err != nil {
+			return nil, err
+		} else if rel == ".." || strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator)) {
+			return nil, fmt.Errorf("$BP_TOMCAT_CONTEXT_PATHS path %s is outside of the application root", strings.TrimSpace(parts[1]))
+		}
+
+		if ok, err := helper.FileExists(path); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("$BP_TOMCAT_CONTEXT_PATHS path %s does not exist", strings.TrimSpace(parts[1]))
+		}
+
+		additional[cp] = path
+	}
+
+	return additional, nil
+}
+
+func contextPath() string {
+	cp, ok := os.LookupEnv("BP_TOMCAT_CONTEXT_PATH")
+	if !ok {
+		cp = "ROOT"
+	}
+
+	return normalizeContextPath(cp)
+}
+
+func normalizeContextPath(cp string) string {
+	cp = regexp.MustCompile("^/").ReplaceAllString(cp, "")
+	if cp == "" {
+		return "ROOT"
+	}
+
+	return strings.ReplaceAll(cp, "/", "#")
+}
+
+func primaryWebApplication(root string, exclude []string) (string, bool, error) {
+	if _, ok := os.LookupEnv("BP_TOMCAT_WAR"); !ok {
+		if ok, err := helper.FileExists(filepath.Join(root, "WEB-INF")); err != nil {
+			return "", false, err
+		} else if ok {
+			return root, true, nil
+		}
+	}
+
+	return findWAR(root, exclude)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
 	"github.com/cloudfoundry/tomcat-cnb/base"
 	"github.com/cloudfoundry/tomcat-cnb/home"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
 )
 
 func main() {
@@ -41,22 +42,54 @@ func main() {
 }
 
 func b(build build.Build) (int, error) {
-	if b, ok, err := base.NewBase(build); err != nil {
+	if ok, err := base.HasWebApplications(build.Application.Root); err != nil {
+		return build.Failure(102), err
+	} else if !ok {
+		return build.Success()
+	}
+
+	// The namespace is only used to select a version of Tomcat, so applications are not scanned when
+	// $BP_TOMCAT_VERSION selects one
+	n := internal.UnknownNamespace
+	if _, ok := os.LookupEnv("BP_TOMCAT_VERSION"); !ok {
+		var err error
+		if n, err = base.ServletNamespace(build.Application.Root); err != nil {
+			return build.Failure(102), err
+		}
+	}
+
+	build.Logger.Title(build.Buildpack)
+
+	h, err := home.NewHome(build, n)
+	if err != nil {
+		return build.Failure(102), err
+	}
+
+	if b, ok, err := base.NewBase(build, h.Dependency(), n); err != nil {
 		return build.Failure(102), err
 	} else if ok {
-		build.Logger.Title(build.Buildpack)
+		d, err := h.Downloads()
+		if err != nil {
+			return build.Failure(103), err
+		}
+
+		bd, err := b.Downloads()
+		if err != nil {
+			return build.Failure(103), err
+		}
+
+		if err := internal.Download(build, append(bd, d...)); err != nil {
+			return build.Failure(103), err
+		}
 
 		if err := b.Contribute(); err != nil {
 			return build.Failure(103), err
 		}
 
-		if h, err := home.NewHome(build); err != nil {
-			return build.Failure(102), err
-		} else {
-			if err := h.Contribute(); err != nil {
-				return build.Failure(103), err
-			}
+		if err := h.Contribute(); err != nil {
+			return build.Failure(103), err
 		}
 	}
+
 	return build.Success()
 }
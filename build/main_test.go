@@ -17,6 +17,7 @@
 package main
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
@@ -36,5 +37,18 @@ func TestBuild(t *testing.T) {
 
 			g.Expect(b(f.Build)).To(gomega.Equal(build.SuccessStatusCode))
 		})
+
+		it("does not scan web applications with $BP_TOMCAT_VERSION", func() {
+			f := test.NewBuildFactory(t)
+			test.WriteFile(t, filepath.Join(f.Build.Application.Root, "WEB-INF", "lib", "invalid.jar"), "invalid")
+
+			_, err := b(f.Build)
+			g.Expect(err).To(gomega.MatchError("zip: not a valid zip file"))
+
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "9.*")()
+
+			_, err = b(f.Build)
+			g.Expect(err).To(gomega.MatchError(gomega.HavePrefix("no valid dependencies for tomcat")))
+		})
 	}, spec.Report(report.Terminal{}))
 }
@@ -22,6 +22,9 @@ import (
 
 	"github.com/buildpacks/libbuildpack/v2/buildplan"
 	"github.com/cloudfoundry/libcfbuildpack/v2/detect"
+	"github.com/cloudfoundry/tomcat-cnb/base"
+	"github.com/cloudfoundry/tomcat-cnb/home"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
 )
 
 func main() {
@@ -40,9 +43,31 @@ func main() {
 }
 
 func d(detect detect.Detect) (int, error) {
+	if ok, err := base.HasWebApplications(detect.Application.Root); err != nil {
+		return detect.Error(102), err
+	} else if !ok {
+		return detect.Fail(), nil
+	}
+
+	tomcat := buildplan.Required{Name: home.TomcatDependency, Metadata: buildplan.Metadata{"launch": true}}
+	if v, ok := os.LookupEnv("BP_TOMCAT_VERSION"); ok {
+		tomcat.Metadata[internal.VersionMetadata] = v
+	}
+
+	if _, err := internal.JakartaMigration(); err != nil {
+		return detect.Error(102), err
+	}
+
+	// A JRE is required at build time so that its version is checked against the selected version of Tomcat, and the
+	// Jakarta EE Migration Tool can be run
 	return detect.Pass(buildplan.Plan{
+		Provides: []buildplan.Provided{
+			{Name: home.TomcatDependency},
+		},
 		Requires: []buildplan.Required{
 			{Name: "jvm-application"},
+			tomcat,
+			{Name: "openjdk-jre", Metadata: buildplan.Metadata{"build": true, "launch": true}},
 		},
 	})
 }
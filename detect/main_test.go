@@ -17,6 +17,8 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/buildpacks/libbuildpack/v2/buildplan"
@@ -38,13 +40,88 @@ func TestDetect(t *testing.T) {
 			f = test.NewDetectFactory(t)
 		})
 
-		it("always passes", func() {
+		it("fails without WEB-INF or WAR", func() {
+			g.Expect(d(f.Detect)).To(gomega.Equal(detect.FailStatusCode))
+		})
+
+		it("passes with WEB-INF", func() {
+			if err := os.MkdirAll(filepath.Join(f.Detect.Application.Root, "WEB-INF"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
 			g.Expect(d(f.Detect)).To(gomega.Equal(detect.PassStatusCode))
 			g.Expect(f.Plans).To(test.HavePlans(buildplan.Plan{
+				Provides: []buildplan.Provided{
+					{Name: "tomcat"},
+				},
 				Requires: []buildplan.Required{
 					{Name: "jvm-application"},
+					{Name: "tomcat", Metadata: buildplan.Metadata{"launch": true}},
+					{Name: "openjdk-jre", Metadata: buildplan.Metadata{"build": true, "launch": true}},
 				},
 			}))
 		})
+
+		it("passes with WAR", func() {
+			test.TouchFile(t, f.Detect.Application.Root, "test.war")
+
+			g.Expect(d(f.Detect)).To(gomega.Equal(detect.PassStatusCode))
+			g.Expect(f.Plans).To(test.HavePlans(buildplan.Plan{
+				Provides: []buildplan.Provided{
+					{Name: "tomcat"},
+				},
+				Requires: []buildplan.Required{
+					{Name: "jvm-application"},
+					{Name: "tomcat", Metadata: buildplan.Metadata{"launch": true}},
+					{Name: "openjdk-jre", Metadata: buildplan.Metadata{"build": true, "launch": true}},
+				},
+			}))
+		})
+
+		it("passes with $BP_TOMCAT_CONTEXT_PATHS", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATHS", "admin=admin")()
+
+			test.TouchFile(t, f.Detect.Application.Root, "admin", "index.jsp")
+
+			g.Expect(d(f.Detect)).To(gomega.Equal(detect.PassStatusCode))
+		})
+
+		it("fails with $BP_TOMCAT_CONTEXT_PATHS outside of the application root", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_CONTEXT_PATHS", "admin=../admin.war")()
+
+			_, err := d(f.Detect)
+			g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_CONTEXT_PATHS path ../admin.war is outside of the application root"))
+		})
+
+		it("requires $BP_TOMCAT_VERSION if set", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "8.*")()
+
+			if err := os.MkdirAll(filepath.Join(f.Detect.Application.Root, "WEB-INF"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			g.Expect(d(f.Detect)).To(gomega.Equal(detect.PassStatusCode))
+			g.Expect(f.Plans).To(test.HavePlans(buildplan.Plan{
+				Provides: []buildplan.Provided{
+					{Name: "tomcat"},
+				},
+				Requires: []buildplan.Required{
+					{Name: "jvm-application"},
+					{Name: "tomcat", Metadata: buildplan.Metadata{"launch": true, "version": "8.*"}},
+					{Name: "openjdk-jre", Metadata: buildplan.Metadata{"build": true, "launch": true}},
+				},
+			}))
+		})
+
+		it("fails with invalid $BP_TOMCAT_JAKARTA_MIGRATION", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "test-value")()
+
+			if err := os.MkdirAll(filepath.Join(f.Detect.Application.Root, "WEB-INF"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := d(f.Detect)
+			g.Expect(err).To(gomega.HaveOccurred())
+		})
 	}, spec.Report(report.Terminal{}))
 }
@@ -1,13 +1,33 @@
 module github.com/cloudfoundry/tomcat-cnb
 
-go 1.13
+go 1.19
 
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/Masterminds/semver v1.5.0
+	github.com/ProtonMail/go-crypto v1.1.3
 	github.com/buildpacks/libbuildpack/v2 v2.0.7
 	github.com/cloudfoundry/libcfbuildpack/v2 v2.1.8
-	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/onsi/gomega v1.9.0
 	github.com/sclevine/spec v1.4.0
+	golang.org/x/sys v0.18.0
+)
+
+require (
+	cloud.google.com/go v0.52.0 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
+	github.com/creack/pty v1.1.9 // indirect
+	github.com/golang/protobuf v1.3.3 // indirect
+	github.com/heroku/color v0.0.6 // indirect
+	github.com/mattn/go-colorable v0.1.4 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/mitchellh/mapstructure v1.1.2 // indirect
+	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
+	golang.org/x/crypto v0.21.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
+	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+	google.golang.org/appengine v1.6.5 // indirect
+	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
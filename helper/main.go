@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+)
+
+func main() {
+	var err error
+
+	// The helper is linked into exec.d directories under the name of the configuration it applies
+	if n := filepath.Base(os.Args[0]); n != "tomcat-helper" {
+		err = x(n, os.NewFile(3, "/dev/fd/3"))
+	} else {
+		err = h(os.Args[1:])
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "tomcat-helper: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func h(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: tomcat-helper <command> [<argument>...]")
+	}
+
+	switch args[0] {
+	case "datasources":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: tomcat-helper datasources <catalina.properties>")
+		}
+
+		return launch.DataSources(args[1])
+	case "tls":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: tomcat-helper tls <destination>")
+		}
+
+		return launch.TLS(args[1])
+	default:
+		return fmt.Errorf("unknown command %s", args[0])
+	}
+}
+
+func x(name string, out io.Writer) error {
+	var env map[string]string
+
+	switch name {
+	case "access-logging":
+		env = launch.AccessLogging()
+	case "datasources":
+		if err := launch.DataSources(launch.PropertiesFile()); err != nil {
+			return err
+		}
+		env = launch.CatalinaConfig(launch.PropertiesFile())
+	case "port":
+		env = launch.Port()
+	case "tls":
+		if err := launch.TLS(launch.TLSDirectory()); err != nil {
+			return err
+		}
+		env = launch.HTTPS(launch.TLSDirectory())
+	default:
+		return fmt.Errorf("unknown exec.d executable %s", name)
+	}
+
+	return launch.WriteEnvironment(out, env)
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestHelper(t *testing.T) {
+	spec.Run(t, "Helper", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		it("fails without command", func() {
+			g.Expect(h([]string{})).To(gomega.MatchError("usage: tomcat-helper <command> [<argument>...]"))
+		})
+
+		it("fails with unknown command", func() {
+			g.Expect(h([]string{"test-command"})).To(gomega.MatchError("unknown command test-command"))
+		})
+
+		it("fails with datasources and no catalina.properties", func() {
+			g.Expect(h([]string{"datasources"})).To(gomega.MatchError("usage: tomcat-helper datasources <catalina.properties>"))
+		})
+
+		it("fails with tls and no destination", func() {
+			g.Expect(h([]string{"tls"})).To(gomega.MatchError("usage: tomcat-helper tls <destination>"))
+		})
+
+		it("fails with unknown exec.d executable", func() {
+			g.Expect(x("test-executable", &bytes.Buffer{})).To(gomega.MatchError("unknown exec.d executable test-executable"))
+		})
+
+		it("writes exec.d environment", func() {
+			defer test.ReplaceEnv(t, "JAVA_OPTS", "-Dtest.key=test-value")()
+			defer test.ReplaceEnv(t, "PORT", "9090")()
+
+			b := &bytes.Buffer{}
+			g.Expect(x("port", b)).To(gomega.Succeed())
+			g.Expect(b.String()).To(gomega.Equal(`BPI_TOMCAT_EXEC_D = "true"
+JAVA_OPTS = "-Dtest.key=test-value -Dport.http=9090"
+`))
+		})
+
+		it("writes exec.d environment to file descriptor 3 when linked into exec.d", func() {
+			root := test.ScratchDir(t, "helper")
+			bin := filepath.Join(root, "bin", "tomcat-helper")
+
+			out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput()
+			g.Expect(err).NotTo(gomega.HaveOccurred(), string(out))
+
+			link := filepath.Join(root, "exec.d", "port")
+			test.WriteSymlink(t, bin, link)
+
+			r, w, err := os.Pipe()
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			defer r.Close()
+
+			cmd := exec.Command(link)
+			cmd.Env = []string{"JAVA_OPTS=-Dtest.key=test-value", "PORT=9090"}
+			cmd.ExtraFiles = []*os.File{w}
+			g.Expect(cmd.Run()).To(gomega.Succeed())
+			g.Expect(w.Close()).To(gomega.Succeed())
+
+			g.Expect(ioutil.ReadAll(r)).To(gomega.Equal([]byte(`BPI_TOMCAT_EXEC_D = "true"
+JAVA_OPTS = "-Dtest.key=test-value -Dport.http=9090"
+`)))
+		})
+
+		it("writes datasources properties and exec.d environment", func() {
+			root := test.ScratchDir(t, "helper")
+			test.WriteFile(t, filepath.Join(root, "home", "conf", "catalina.properties"), "test.key=test-value\n")
+			defer test.ReplaceEnv(t, "CATALINA_HOME", filepath.Join(root, "home"))()
+			defer test.ReplaceEnv(t, "CNB_SERVICES", `{}`)()
+			defer test.ReplaceEnv(t, "JAVA_OPTS", "")()
+			defer test.ReplaceEnv(t, "TMPDIR", filepath.Join(root, "tmp"))()
+
+			b := &bytes.Buffer{}
+			g.Expect(x("datasources", b)).To(gomega.Succeed())
+
+			file := filepath.Join(root, "tmp", "tomcat", "catalina.properties")
+			g.Expect(ioutil.ReadFile(file)).To(gomega.ContainSubstring("tomcat.datasource.max-total=8"))
+			g.Expect(b.String()).To(gomega.Equal(fmt.Sprintf(`BPI_TOMCAT_EXEC_D = "true"
+JAVA_OPTS = "-Dcatalina.config=file:%s"
+`, file)))
+		})
+
+		it("writes TLS files outside of $CATALINA_BASE and exec.d environment", func() {
+			root := test.ScratchDir(t, "helper")
+			defer test.ReplaceEnv(t, "BPL_TOMCAT_HTTPS_PORT", "")()
+			defer test.ReplaceEnv(t, "CATALINA_BASE", filepath.Join(root, "base"))()
+			defer test.ReplaceEnv(t, "CNB_SERVICES", `{
+  "user-provided": [
+    {
+      "binding_name": "tls",
+      "credentials": { "certificate": "test-certificate", "private_key": "test-private-key" }
+    }
+  ]
+}`)()
+			defer test.ReplaceEnv(t, "JAVA_OPTS", "")()
+			defer test.ReplaceEnv(t, "TMPDIR", filepath.Join(root, "tmp"))()
+
+			b := &bytes.Buffer{}
+			g.Expect(x("tls", b)).To(gomega.Succeed())
+
+			directory := filepath.Join(root, "tmp", "tomcat", "tls")
+			g.Expect(filepath.Join(directory, "private-key.pem")).To(test.HaveContent("test-private-key"))
+			g.Expect(filepath.Join(root, "base")).NotTo(gomega.BeAnExistingFile())
+			g.Expect(b.String()).To(gomega.Equal(fmt.Sprintf(`BPI_TOMCAT_EXEC_D = "true"
+JAVA_OPTS = "-Dport.https=8443 -Dtomcat.tls.directory=%s"
+`, directory)))
+		})
+	}, spec.Report(report.Terminal{}))
+}
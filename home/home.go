@@ -17,68 +17,359 @@
 package home
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
 	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
 	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
 	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
 	"github.com/cloudfoundry/tomcat-cnb/internal"
 )
 
-// TomcatDependency indicates that Tomcat is required for the web application.
-const TomcatDependency = "tomcat"
+const (
+	// KeysFile is the name of the file in the buildpack root containing the public keys that Tomcat distributions are
+	// signed with.
+	KeysFile = "KEYS"
+
+	// Launcher is the name of the executable, contributed to CATALINA_HOME/bin, that starts Tomcat in the foreground.
+	Launcher = "tomcat-launcher"
+
+	// TomcatDependency indicates that Tomcat is required for the web application.
+	TomcatDependency = "tomcat"
+)
+
+// Trimmed are the files, relative to CATALINA_HOME, that are not required by "catalina.sh run" and are removed from a
+// minimal CATALINA_HOME.
+var Trimmed = []string{
+	"BUILDING.txt",
+	"CONTRIBUTING.md",
+	"README.md",
+	"RELEASE-NOTES",
+	"RUNNING.txt",
+	"bin/*.bat",
+	"bin/*.tar.gz",
+	"webapps/ROOT",
+	"webapps/docs",
+	"webapps/examples",
+	"webapps/host-manager",
+	"webapps/manager",
+}
 
 type Home struct {
-	layer  layers.DependencyLayer
-	layers layers.Layers
+	layer     layers.DependencyLayer
+	layers    layers.Layers
+	buildpack buildpack.Buildpack
+	trimmed   []string
+	keys      string
+	signature string
+	processes layers.Processes
+	override  bool
+}
+
+// Dependency returns the Tomcat dependency contributed as CATALINA_HOME.
+func (h Home) Dependency() buildpack.Dependency {
+	return h.layer.Dependency
+}
+
+// Downloads returns the dependencies that must be downloaded to contribute the Tomcat home, none if the layer from a
+// previous build can be reused.
+func (h Home) Downloads() ([]buildpack.Dependency, error) {
+	if ok, err := h.metadataMatches(h.marker()); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, nil
+	}
+
+	return []buildpack.Dependency{h.layer.Dependency}, nil
 }
 
 func (h Home) Contribute() error {
+	m := h.marker()
+
+	// The dependency layer only compares the dependency, so remove metadata recording different trim patterns or
+	// launcher version to force the layer to be contributed again.  A reused layer keeps the record of the files that
+	// were removed from it.
+	if ok, err := h.metadataMatches(m); err != nil {
+		return err
+	} else if ok {
+		var actual marker
+		if err := h.layer.ReadMetadata(&actual); err != nil {
+			return err
+		}
+		m.Trimmed = actual.Trimmed
+	} else if err := os.RemoveAll(h.layer.Metadata); err != nil {
+		return err
+	}
+
 	if err := h.layer.Contribute(func(artifact string, layer layers.DependencyLayer) error {
+		if h.keys != "" {
+			layer.Logger.Body("Verifying signature with %s", filepath.Base(h.keys))
+			if err := internal.VerifySignature(artifact, h.signature, h.keys); err != nil {
+				return err
+			}
+		}
+
 		layer.Logger.Body("Extracting to %s", layer.Root)
 
 		if err := helper.ExtractTarGz(artifact, layer.Root, 1); err != nil {
 			return err
 		}
 
-		return layer.OverrideLaunchEnv("CATALINA_HOME", layer.Root)
+		t, err := h.trim(layer)
+		if err != nil {
+			return err
+		}
+		m.Trimmed = t
+
+		layer.Logger.Body("Copying %s to %s/bin", Launcher, layer.Root)
+		if err := helper.CopyFile(filepath.Join(h.buildpack.Root, "bin", Launcher), filepath.Join(layer.Root, "bin", Launcher)); err != nil {
+			return err
+		}
+
+		if err := layer.OverrideLaunchEnv("CATALINA_HOME", layer.Root); err != nil {
+			return err
+		}
+
+		return internal.Normalize(layer.Root)
 	}, layers.Launch); err != nil {
 		return err
 	}
 
-	command := "catalina.sh run"
+	if err := h.layer.WriteMetadata(m, layers.Launch); err != nil {
+		return err
+	}
+
+	return h.layers.WriteApplicationMetadata(layers.Metadata{Processes: h.processes})
+}
+
+func (h Home) marker() marker {
+	return marker{h.layer.Dependency, h.trimmed, nil, h.buildpack.Info.Version, h.override}
+}
+
+// metadataMatches returns whether the layer metadata matches a marker, ignoring the files that were removed from the
+// layer as they are only known once it is contributed.
+func (h Home) metadataMatches(expected marker) (bool, error) {
+	h.layer.Touch()
+
+	var actual marker
+	if err := h.layer.ReadMetadata(&actual); err != nil {
+		h.layer.Logger.Debug("Dependency metadata is not structured correctly: %s", err.Error())
+		return false, nil
+	}
 
-	return h.layers.WriteApplicationMetadata(layers.Metadata{
-		Processes: layers.Processes{
-			{Type: "task", Command: command},
-			{Type: "tomcat", Command: command},
-			{Type: "web", Command: command},
-		},
-	})
+	actual.Trimmed, expected.Trimmed = nil, nil
+	return reflect.DeepEqual(actual, expected), nil
 }
 
-// NewHome creates a new CATALINA_HOME instance.
-func NewHome(build build.Build) (Home, error) {
-	p, _, err := build.Plans.GetShallowMerged(TomcatDependency)
+// trim removes the files matching the trim patterns from a layer and returns their paths, relative to the layer.
+func (h Home) trim(layer layers.DependencyLayer) ([]string, error) {
+	var trimmed []string
+
+	for _, t := range h.trimmed {
+		matches, err := filepath.Glob(filepath.Join(layer.Root, t))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range matches {
+			rel, err := filepath.Rel(layer.Root, m)
+			if err != nil {
+				return nil, err
+			}
+
+			layer.Logger.Body("Removing %s", rel)
+			if err := os.RemoveAll(m); err != nil {
+				return nil, err
+			}
+
+			trimmed = append(trimmed, filepath.ToSlash(rel))
+		}
+	}
+
+	return trimmed, nil
+}
+
+type marker struct {
+	buildpack.Dependency
+
+	Trim     []string `toml:"trim"`
+	Trimmed  []string `toml:"trimmed"`
+	Launcher string   `toml:"launcher"`
+	Override bool     `toml:"override"`
+}
+
+// NewHome creates a new CATALINA_HOME instance.  The namespace of the web applications' Servlet API is used to select a
+// compatible version of Tomcat if no version is otherwise requested.
+func NewHome(build build.Build, namespace internal.Namespace) (Home, error) {
+	plans := build.Plans.Get(TomcatDependency)
+
+	deps, err := internal.Dependencies(build)
 	if err != nil {
 		return Home{}, err
 	}
 
-	deps, err := build.Buildpack.Dependencies()
+	version, err := internal.Version(TomcatDependency, plans, build.Buildpack, deps, namespace)
 	if err != nil {
 		return Home{}, err
 	}
 
-	version, err := internal.Version(TomcatDependency, p, build.Buildpack)
+	dep, err := deps.Best(TomcatDependency, version, build.Stack)
 	if err != nil {
+		requested := 0
+		for _, p := range plans {
+			if internal.PlanVersion(p) != "" {
+				requested++
+			}
+		}
+
+		if _, ok := os.LookupEnv("BP_TOMCAT_VERSION"); !ok && requested > 1 {
+			return Home{}, fmt.Errorf("no version of %s satisfies every build plan requirement (%s): %w",
+				TomcatDependency, internal.Requirements(plans), err)
+		}
 		return Home{}, err
 	}
 
-	dep, err := deps.Best(TomcatDependency, version, build.Stack)
+	override := internal.Overridden(TomcatDependency)
+	if override {
+		build.Logger.HeaderWarning("Using user supplied %s %s", dep.Name, dep.Version.Original())
+	}
+
+	if err := java(build, deps, dep); err != nil {
+		return Home{}, err
+	}
+
+	if err := support(build, deps, dep); err != nil {
+		return Home{}, err
+	}
+
+	var keys, sig string
+	if override {
+		keys, sig, err = overrideSignature(build)
+	} else {
+		keys, sig, err = signature(build.Buildpack, dep)
+	}
 	if err != nil {
 		return Home{}, err
 	}
 
+	proc, err := processes(plans)
+	if err != nil {
+		return Home{}, err
+	}
+
+	var trimmed []string
+	if m, err := minimal(); err != nil {
+		return Home{}, err
+	} else if m {
+		trimmed = Trimmed
+	}
+
 	return Home{
 		build.Layers.DependencyLayer(dep),
 		build.Layers,
+		build.Buildpack,
+		trimmed,
+		keys,
+		sig,
+		proc,
+		override,
 	}, nil
 }
+
+func minimal() (bool, error) {
+	s, ok := os.LookupEnv("BP_TOMCAT_MINIMAL_HOME")
+	if !ok {
+		return true, nil
+	}
+
+	m, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("$BP_TOMCAT_MINIMAL_HOME must be a boolean: %w", err)
+	}
+
+	return m, nil
+}
+
+// signature returns the KEYS file and detached signature to verify a Tomcat distribution with.  The buildpack must
+// contain a KEYS file and the dependency's entry in buildpack.toml must have a signature, the location of an armored
+// detached signature relative to the buildpack root.
+func signature(b buildpack.Buildpack, dependency buildpack.Dependency) (string, string, error) {
+	keys := filepath.Join(b.Root, KeysFile)
+
+	if ok, err := helper.FileExists(keys); err != nil {
+		return "", "", err
+	} else if !ok {
+		return "", "", fmt.Errorf("unable to find %s in buildpack", KeysFile)
+	}
+
+	s, ok := entry(b, dependency)["signature"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("no signature for %s %s in buildpack.toml", dependency.ID, dependency.Version.Original())
+	}
+
+	sig := filepath.Join(b.Root, s)
+	if ok, err := helper.FileExists(sig); err != nil {
+		return "", "", err
+	} else if !ok {
+		return "", "", fmt.Errorf("unable to find signature %s in buildpack", s)
+	}
+
+	return keys, sig, nil
+}
+
+// overrideSignature returns the KEYS file and detached signature to verify an overridden Tomcat distribution with.  The
+// signature is read from $BP_TOMCAT_OVERRIDE_TOMCAT_SIGNATURE and the KEYS file from $BP_TOMCAT_OVERRIDE_TOMCAT_KEYS,
+// both relative to the application root, or from the buildpack if it is not set.  Without a signature the build fails
+// unless $BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE is true, in which case the distribution is not verified.
+func overrideSignature(build build.Build) (string, string, error) {
+	s, ok := os.LookupEnv("BP_TOMCAT_OVERRIDE_TOMCAT_SIGNATURE")
+	if !ok {
+		skip := false
+		if v, ok := os.LookupEnv("BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE"); ok {
+			var err error
+			if skip, err = strconv.ParseBool(v); err != nil {
+				return "", "", fmt.Errorf("$BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE must be a boolean: %w", err)
+			}
+		}
+
+		if !skip {
+			return "", "", fmt.Errorf("$BP_TOMCAT_OVERRIDE_TOMCAT_URI requires $BP_TOMCAT_OVERRIDE_TOMCAT_SIGNATURE, or $BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE to skip verification")
+		}
+
+		build.Logger.HeaderWarning("Not verifying the signature of user supplied %s", TomcatDependency)
+		return "", "", nil
+	}
+
+	keys := filepath.Join(build.Buildpack.Root, KeysFile)
+	if k, ok := os.LookupEnv("BP_TOMCAT_OVERRIDE_TOMCAT_KEYS"); ok {
+		keys = filepath.Join(build.Application.Root, k)
+	}
+
+	sig := filepath.Join(build.Application.Root, s)
+
+	for _, f := range []string{keys, sig} {
+		if ok, err := helper.FileExists(f); err != nil {
+			return "", "", err
+		} else if !ok {
+			return "", "", fmt.Errorf("unable to find %s", f)
+		}
+	}
+
+	return keys, sig, nil
+}
+
+// entry returns the raw buildpack.toml entry of a dependency, so that keys unknown to buildpack.Dependency can be read.
+func entry(b buildpack.Buildpack, dependency buildpack.Dependency) map[string]interface{} {
+	deps, _ := b.Metadata[buildpack.DependenciesMetadata].([]map[string]interface{})
+	for _, d := range deps {
+		if d["id"] == dependency.ID && d["version"] == dependency.Version.Original() && d["sha256"] == dependency.SHA256 {
+			return d
+		}
+	}
+
+	return nil
+}
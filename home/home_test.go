@@ -17,13 +17,20 @@
 package home_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/BurntSushi/toml"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpackplan"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
 	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
 	"github.com/cloudfoundry/libcfbuildpack/v2/test"
 	"github.com/cloudfoundry/tomcat-cnb/home"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	itest "github.com/cloudfoundry/tomcat-cnb/internal/test"
 	"github.com/onsi/gomega"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -38,15 +45,17 @@ func TestHome(t *testing.T) {
 
 		it.Before(func() {
 			f = test.NewBuildFactory(t)
+			test.TouchFile(t, f.Build.Buildpack.Root, "bin", "tomcat-launcher")
 		})
 
 		it("returns true with WEB-INF", func() {
 			f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
 			if err := os.MkdirAll(filepath.Join(f.Build.Application.Root, "WEB-INF"), 0755); err != nil {
 				t.Fatal(err)
 			}
 
-			h, err := home.NewHome(f.Build)
+			h, err := home.NewHome(f.Build, internal.UnknownNamespace)
 			g.Expect(err).NotTo(gomega.HaveOccurred())
 
 			g.Expect(h.Contribute()).To(gomega.Succeed())
@@ -56,14 +65,344 @@ func TestHome(t *testing.T) {
 			g.Expect(filepath.Join(layer.Root, "fixture-marker")).To(gomega.BeARegularFile())
 			g.Expect(layer).To(test.HaveOverrideLaunchEnvironment("CATALINA_HOME", layer.Root))
 
-			command := "catalina.sh run"
+			command := "tomcat-launcher"
 			g.Expect(f.Build.Layers).To(test.HaveApplicationMetadata(layers.Metadata{
 				Processes: []layers.Process{
-					{Type: "task", Command: command},
-					{Type: "tomcat", Command: command},
-					{Type: "web", Command: command},
+					{Type: "task", Command: command, Direct: true},
+					{Type: "tomcat", Command: command, Direct: true},
+					{Type: "web", Command: command, Direct: true},
+					{Type: "web-debug", Command: command, Args: []string{home.Processes["web-debug"]}, Direct: true},
 				},
 			}))
 		})
+
+		it("selects Tomcat 10 for Jakarta namespace", func() {
+			f.AddDependencyWithVersion("tomcat", "9.0.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			f.AddDependencyWithVersion("tomcat", "10.0.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+			f.SetDefaultVersion("tomcat", "9.*")
+
+			h, err := home.NewHome(f.Build, internal.Jakarta)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(h.Dependency().Version.Original()).To(gomega.Equal("10.0.0"))
+		})
+
+		it("intersects build plan versions", func() {
+			f.AddDependencyWithVersion("tomcat", "9.0.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			f.AddDependencyWithVersion("tomcat", "9.0.30", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			f.AddDependencyWithVersion("tomcat", "10.0.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+			f.AddPlan(buildpackplan.Plan{Name: "tomcat", Version: ">= 9.0.30"})
+			f.AddPlan(buildpackplan.Plan{Name: "tomcat", Version: "9.*"})
+
+			h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(h.Dependency().Version.Original()).To(gomega.Equal("9.0.30"))
+		})
+
+		it("fails with unsatisfiable build plan versions", func() {
+			f.AddDependencyWithVersion("tomcat", "8.5.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			f.AddDependencyWithVersion("tomcat", "9.0.30", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+			f.AddPlan(buildpackplan.Plan{Name: "tomcat", Version: ">= 9.0.30"})
+			f.AddPlan(buildpackplan.Plan{Name: "tomcat", Version: "8.*"})
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError(gomega.HavePrefix(
+				`no version of tomcat satisfies every build plan requirement (">= 9.0.30", "8.*")`)))
+		})
+
+		it("records override in layer metadata", func() {
+			f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+			d, err := f.Build.Buildpack.Dependencies()
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_VERSION", d[0].Version.Original())()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_URI", d[0].URI)()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SHA256", d[0].SHA256)()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE", "true")()
+
+			h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(h.Contribute()).To(gomega.Succeed())
+
+			var metadata struct {
+				Metadata struct {
+					Override bool `toml:"override"`
+				} `toml:"metadata"`
+			}
+			_, err = toml.DecodeFile(f.Build.Layers.Layer("tomcat").Metadata, &metadata)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(metadata.Metadata.Override).To(gomega.BeTrue())
+		})
+
+		it("contributes identical layers when rebuilt", func() {
+			defer test.ReplaceEnv(t, "SOURCE_DATE_EPOCH", "1577836800")()
+			f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+
+			h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(h.Contribute()).To(gomega.Succeed())
+
+			layer := f.Build.Layers.Layer("tomcat")
+			first := itest.Digest(t, layer.Root)
+			g.Expect(first).To(gomega.HaveKeyWithValue("/fixture-marker", gomega.ContainSubstring("2020-01-01 00:00:00 +0000 UTC")))
+
+			g.Expect(os.RemoveAll(layer.Root)).To(gomega.Succeed())
+			g.Expect(os.RemoveAll(layer.Metadata)).To(gomega.Succeed())
+			g.Expect(h.Contribute()).To(gomega.Succeed())
+
+			g.Expect(itest.Digest(t, layer.Root)).To(gomega.Equal(first))
+		})
+
+		when("Tomcat distribution", func() {
+
+			it.Before(func() {
+				f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat-distribution.tar.gz"))
+				itest.SignDependencies(t, f)
+			})
+
+			it("contributes minimal CATALINA_HOME", func() {
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("tomcat")
+				g.Expect(filepath.Join(layer.Root, "bin", "catalina.sh")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(layer.Root, "lib", "catalina.jar")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(layer.Root, "bin", "catalina.bat")).NotTo(gomega.BeAnExistingFile())
+				g.Expect(filepath.Join(layer.Root, "RELEASE-NOTES")).NotTo(gomega.BeAnExistingFile())
+				g.Expect(filepath.Join(layer.Root, "webapps", "docs")).NotTo(gomega.BeAnExistingFile())
+				g.Expect(filepath.Join(layer.Root, "webapps", "manager")).NotTo(gomega.BeAnExistingFile())
+
+				var metadata struct {
+					Metadata struct {
+						Trim    []string `toml:"trim"`
+						Trimmed []string `toml:"trimmed"`
+					} `toml:"metadata"`
+				}
+				_, err = toml.DecodeFile(layer.Metadata, &metadata)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(metadata.Metadata.Trim).To(gomega.Equal(home.Trimmed))
+				g.Expect(metadata.Metadata.Trimmed).To(gomega.Equal([]string{"RELEASE-NOTES", "bin/catalina.bat", "webapps/docs", "webapps/manager"}))
+			})
+
+			it("reuses minimal CATALINA_HOME and keeps the removed files in its metadata", func() {
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				g.Expect(h.Downloads()).To(gomega.BeEmpty())
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				var metadata struct {
+					Metadata struct {
+						Trimmed []string `toml:"trimmed"`
+					} `toml:"metadata"`
+				}
+				_, err = toml.DecodeFile(f.Build.Layers.Layer("tomcat").Metadata, &metadata)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(metadata.Metadata.Trimmed).To(gomega.Equal([]string{"RELEASE-NOTES", "bin/catalina.bat", "webapps/docs", "webapps/manager"}))
+			})
+
+			it("contributes complete CATALINA_HOME with $BP_TOMCAT_MINIMAL_HOME=false", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_MINIMAL_HOME", "false")()
+
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("tomcat")
+				g.Expect(filepath.Join(layer.Root, "bin", "catalina.bat")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(layer.Root, "RELEASE-NOTES")).To(gomega.BeARegularFile())
+				g.Expect(filepath.Join(layer.Root, "webapps", "docs")).To(gomega.BeADirectory())
+			})
+
+			it("contributes again when trimming changes", func() {
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				defer test.ReplaceEnv(t, "BP_TOMCAT_MINIMAL_HOME", "false")()
+
+				h, err = home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("tomcat")
+				g.Expect(filepath.Join(layer.Root, "RELEASE-NOTES")).To(gomega.BeARegularFile())
+			})
+
+			it("reuses minimal CATALINA_HOME", func() {
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				layer := f.Build.Layers.Layer("tomcat")
+				g.Expect(os.Remove(filepath.Join(layer.Root, "fixture-marker"))).To(gomega.Succeed())
+
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				g.Expect(filepath.Join(layer.Root, "fixture-marker")).NotTo(gomega.BeAnExistingFile())
+			})
+
+			it("downloads Tomcat unless reused", func() {
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(h.Downloads()).To(gomega.Equal([]buildpack.Dependency{h.Dependency()}))
+
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				g.Expect(h.Downloads()).To(gomega.BeEmpty())
+			})
+
+			it("fails with invalid $BP_TOMCAT_MINIMAL_HOME", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_MINIMAL_HOME", "test-value")()
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.HaveOccurred())
+			})
+		})
+
+		it("fails without KEYS", func() {
+			f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError("unable to find KEYS in buildpack"))
+		})
+
+		when("KEYS", func() {
+
+			it.Before(func() {
+				if err := helper.CopyFile(filepath.Join("testdata", "KEYS"), filepath.Join(f.Build.Buildpack.Root, "KEYS")); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := helper.CopyFile(filepath.Join("testdata", "stub-tomcat.tar.gz.asc"),
+					filepath.Join(f.Build.Buildpack.Root, "signatures", "stub-tomcat.tar.gz.asc")); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			sign := func() {
+				deps := f.Build.Buildpack.Metadata[buildpack.DependenciesMetadata].([]map[string]interface{})
+				deps[0]["signature"] = "signatures/stub-tomcat.tar.gz.asc"
+			}
+
+			it("verifies signature", func() {
+				f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+				sign()
+
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(h.Contribute()).To(gomega.Succeed())
+
+				g.Expect(filepath.Join(f.Build.Layers.Layer("tomcat").Root, "fixture-marker")).To(gomega.BeARegularFile())
+			})
+
+			it("fails with invalid signature", func() {
+				f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat-distribution.tar.gz"))
+				sign()
+
+				h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(h.Contribute()).To(gomega.MatchError(gomega.HavePrefix("unable to verify signature of")))
+				g.Expect(filepath.Join(f.Build.Layers.Layer("tomcat").Root, "fixture-marker")).NotTo(gomega.BeAnExistingFile())
+			})
+
+			it("fails without signature", func() {
+				f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("no signature for tomcat 1.0 in buildpack.toml"))
+			})
+
+			when("override", func() {
+
+				override := func(fixture string) func() {
+					f.AddDependency("tomcat", filepath.Join("testdata", fixture))
+					d, err := f.Build.Buildpack.Dependencies()
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					if err := helper.CopyFile(filepath.Join("testdata", "stub-tomcat.tar.gz.asc"),
+						filepath.Join(f.Build.Application.Root, "tomcat.tar.gz.asc")); err != nil {
+						t.Fatal(err)
+					}
+
+					v := test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_VERSION", d[0].Version.Original())
+					u := test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_URI", d[0].URI)
+					s := test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SHA256", d[0].SHA256)
+					return func() { s(); u(); v() }
+				}
+
+				it("verifies signature of override", func() {
+					defer override("stub-tomcat.tar.gz")()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SIGNATURE", "tomcat.tar.gz.asc")()
+
+					h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(h.Contribute()).To(gomega.Succeed())
+
+					g.Expect(filepath.Join(f.Build.Layers.Layer("tomcat").Root, "fixture-marker")).To(gomega.BeARegularFile())
+				})
+
+				it("fails with invalid signature of override", func() {
+					defer override("stub-tomcat-distribution.tar.gz")()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SIGNATURE", "tomcat.tar.gz.asc")()
+
+					h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(h.Contribute()).To(gomega.MatchError(gomega.HavePrefix("unable to verify signature of")))
+				})
+
+				it("fails with override KEYS file that does not exist", func() {
+					defer override("stub-tomcat.tar.gz")()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SIGNATURE", "tomcat.tar.gz.asc")()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_KEYS", "KEYS")()
+
+					_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+					g.Expect(err).To(gomega.MatchError(fmt.Sprintf("unable to find %s", filepath.Join(f.Build.Application.Root, "KEYS"))))
+				})
+
+				it("fails with override without signature", func() {
+					defer override("stub-tomcat.tar.gz")()
+
+					_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+					g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_OVERRIDE_TOMCAT_URI requires $BP_TOMCAT_OVERRIDE_TOMCAT_SIGNATURE, or $BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE to skip verification"))
+				})
+
+				it("does not verify override with $BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE", func() {
+					defer override("stub-tomcat-distribution.tar.gz")()
+					defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SKIP_SIGNATURE", "true")()
+
+					h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+
+					g.Expect(h.Contribute()).To(gomega.Succeed())
+				})
+			})
+
+			it("fails without signature file", func() {
+				f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+				deps := f.Build.Buildpack.Metadata[buildpack.DependenciesMetadata].([]map[string]interface{})
+				deps[0]["signature"] = "signatures/missing.asc"
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("unable to find signature signatures/missing.asc in buildpack"))
+			})
+		})
 	}, spec.Report(report.Terminal{}))
 }
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package home
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+)
+
+// JavaCompatibility is the key for the buildpack metadata that maps versions of Tomcat to the versions of Java they
+// support.
+const JavaCompatibility = "java-compatibility"
+
+// java fails if the version of the JRE at $JAVA_HOME, which the JRE buildpack contributes to the build environment, is
+// not compatible with the selected version of Tomcat.  If the version of Java is unknown, or the "java-compatibility"
+// buildpack metadata has no entry for the version of Tomcat, no check is made.
+func java(build build.Build, deps buildpack.Dependencies, dependency buildpack.Dependency) error {
+	h, ok := os.LookupEnv("JAVA_HOME")
+	if !ok || h == "" {
+		build.Logger.Debug("$JAVA_HOME not set, skipping Java compatibility check")
+		return nil
+	}
+
+	v, err := launch.JavaVersion(h)
+	if err != nil {
+		return err
+	} else if v == 0 {
+		build.Logger.Debug("Java version unknown, skipping Java compatibility check")
+		return nil
+	}
+	source := fmt.Sprintf("the JRE at %s", h)
+
+	required, ok, err := javaConstraint(build.Buildpack, dependency)
+	if err != nil {
+		return err
+	} else if !ok || required.Check(semver.MustParse(strconv.Itoa(v))) {
+		return nil
+	}
+
+	var compatible []*semver.Version
+	for _, d := range deps {
+		if d.ID != dependency.ID {
+			continue
+		}
+
+		if c, ok, err := javaConstraint(build.Buildpack, d); err != nil {
+			return err
+		} else if ok && c.Check(semver.MustParse(strconv.Itoa(v))) {
+			compatible = append(compatible, d.Version.Version)
+		}
+	}
+
+	if len(compatible) == 0 {
+		return fmt.Errorf("%s %s requires Java %s, but Java %d is provided by %s, and no version of %s is compatible with Java %d",
+			dependency.Name, dependency.Version.Original(), required.raw, v, source, dependency.ID, v)
+	}
+
+	sort.Sort(semver.Collection(compatible))
+
+	var versions []string
+	for _, c := range compatible {
+		if len(versions) == 0 || versions[len(versions)-1] != c.Original() {
+			versions = append(versions, c.Original())
+		}
+	}
+
+	return fmt.Errorf("%s %s requires Java %s, but Java %d is provided by %s.  Versions of %s compatible with Java %d: %s",
+		dependency.Name, dependency.Version.Original(), required.raw, v, source, dependency.ID, v, strings.Join(versions, ", "))
+}
+
+type constraint struct {
+	*semver.Constraints
+
+	raw string
+}
+
+// javaConstraint returns the versions of Java that a version of Tomcat supports.
+func javaConstraint(b buildpack.Buildpack, dependency buildpack.Dependency) (constraint, bool, error) {
+	entries, _ := b.Metadata[JavaCompatibility].([]map[string]interface{})
+
+	for _, e := range entries {
+		t, ok := e["tomcat"].(string)
+		if !ok {
+			return constraint{}, false, fmt.Errorf("%s entry does not have a tomcat version", JavaCompatibility)
+		}
+
+		tc, err := semver.NewConstraint(t)
+		if err != nil {
+			return constraint{}, false, fmt.Errorf("%s entry has an invalid tomcat version: %w", JavaCompatibility, err)
+		}
+
+		if !tc.Check(dependency.Version.Version) {
+			continue
+		}
+
+		j, ok := e["java"].(string)
+		if !ok {
+			return constraint{}, false, fmt.Errorf("%s entry for tomcat %s does not have a java version", JavaCompatibility, t)
+		}
+
+		jc, err := semver.NewConstraint(j)
+		if err != nil {
+			return constraint{}, false, fmt.Errorf("%s entry for tomcat %s has an invalid java version: %w", JavaCompatibility, t, err)
+		}
+
+		return constraint{jc, j}, true, nil
+	}
+
+	return constraint{}, false, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package home_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/home"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	itest "github.com/cloudfoundry/tomcat-cnb/internal/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestJava(t *testing.T) {
+	spec.Run(t, "Java", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var f *test.BuildFactory
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+			f.AddDependencyWithVersion("tomcat", "7.0.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			f.AddDependencyWithVersion("tomcat", "9.0.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			f.AddDependencyWithVersion("tomcat", "10.1.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+			f.Build.Buildpack.Metadata[home.JavaCompatibility] = []map[string]interface{}{
+				{"tomcat": "7.*", "java": ">= 6"},
+				{"tomcat": "9.*", "java": ">= 8"},
+				{"tomcat": "10.1.*", "java": ">= 11"},
+			}
+		})
+
+		jre := func(version string) func() {
+			root := test.ScratchDir(t, "jre")
+			test.WriteFile(t, filepath.Join(root, "release"), "JAVA_VERSION=\"%s\"\n", version)
+			return test.ReplaceEnv(t, "JAVA_HOME", root)
+		}
+
+		it("does not check without $JAVA_HOME", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "10.1.*")()
+			defer test.ReplaceEnv(t, "JAVA_HOME", "")()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+		})
+
+		it("does not check without Java version", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "10.1.*")()
+			defer test.ReplaceEnv(t, "JAVA_HOME", test.ScratchDir(t, "jre"))()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+		})
+
+		it("passes with compatible Java version", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "10.1.*")()
+			defer jre("11.0.7")()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+		})
+
+		it("fails with incompatible Java version", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "10.1.*")()
+			defer jre("1.8.0_252")()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError(gomega.MatchRegexp(`^stub-tomcat.tar.gz 10.1.0 requires Java >= 11, but Java 8 is ` +
+				`provided by the JRE at .+\.  Versions of tomcat compatible with Java 8: 7.0.0, 9.0.0$`)))
+		})
+
+		it("fails with no compatible version", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "9.*")()
+			defer jre("1.5.0")()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError(gomega.MatchRegexp(`^stub-tomcat.tar.gz 9.0.0 requires Java >= 8, but Java 5 is ` +
+				`provided by the JRE at .+, and no version of tomcat is compatible with Java 5$`)))
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package home
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpackplan"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+)
+
+const (
+	// DefaultCommand is the command that starts Tomcat in the foreground.
+	DefaultCommand = Launcher
+
+	// DefaultProcess is the process type that is started by default.
+	DefaultProcess = "web"
+
+	// ProcessesMetadata is the build plan metadata key, on a tomcat entry, that other buildpacks use to contribute
+	// process types.  Its value is a table of process type to the JVM options it adds to $CATALINA_OPTS.
+	ProcessesMetadata = "processes"
+
+	// JMXProcess is the process type, contributed only with $BP_TOMCAT_JMX, that starts Tomcat with an
+	// unauthenticated remote JMX connector.
+	JMXProcess = "web-jmx"
+)
+
+// Processes are the process types, other than the default, that Tomcat is always contributed with.  Each is mapped to
+// the JVM options it adds to $CATALINA_OPTS and is configured at launch.
+var Processes = map[string]string{
+	"web-debug": "-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=${BPL_TOMCAT_DEBUG_ADDRESS:-8000}",
+}
+
+// JMXOptions are the JVM options of the JMXProcess.  The connector has neither authentication nor SSL, and is only
+// reachable through a tunnel to the container, as RMI stubs name 127.0.0.1.
+var JMXOptions = "-Djava.rmi.server.hostname=127.0.0.1 " +
+	"-Dcom.sun.management.jmxremote.authenticate=false " +
+	"-Dcom.sun.management.jmxremote.ssl=false " +
+	"-Dcom.sun.management.jmxremote.port=${BPL_TOMCAT_JMX_PORT:-5000} " +
+	"-Dcom.sun.management.jmxremote.rmi.port=${BPL_TOMCAT_JMX_PORT:-5000}"
+
+var reserved = []string{"task", "tomcat", DefaultProcess}
+
+// processes returns the process types to launch Tomcat with.  Process types are contributed by the buildpack, other
+// buildpacks through the build plan, and $BP_TOMCAT_PROCESS_<TYPE> in increasing order of precedence.  The web process
+// type runs the same command as the process type named by $BP_TOMCAT_DEFAULT_PROCESS.  The launcher is run directly,
+// with the JVM options of a process type as its argument, while $BP_TOMCAT_COMMAND is run by a shell with the JVM
+// options added to $CATALINA_OPTS.
+func processes(plans []buildpackplan.Plan) (layers.Processes, error) {
+	options := make(map[string]string)
+	for k, v := range Processes {
+		options[k] = v
+	}
+
+	if j, err := jmx(); err != nil {
+		return nil, err
+	} else if j {
+		options[JMXProcess] = JMXOptions
+	}
+
+	planned, err := plannedProcesses(plans)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range planned {
+		options[k] = v
+	}
+
+	for k, v := range configuredProcesses() {
+		options[k] = v
+	}
+
+	command, custom := os.LookupEnv("BP_TOMCAT_COMMAND")
+	if !custom {
+		command = DefaultCommand
+	}
+
+	commands := make(map[string]layers.Process)
+	for _, r := range reserved {
+		commands[r] = layers.Process{Command: command, Direct: !custom}
+	}
+
+	for k, v := range options {
+		if isReserved(k) {
+			return nil, fmt.Errorf("process type %s is reserved", k)
+		}
+
+		if custom {
+			commands[k] = layers.Process{Command: fmt.Sprintf(`CATALINA_OPTS="$CATALINA_OPTS %s" %s`, v, command)}
+		} else {
+			commands[k] = layers.Process{Command: command, Args: []string{v}, Direct: true}
+		}
+	}
+
+	if d, ok := os.LookupEnv("BP_TOMCAT_DEFAULT_PROCESS"); ok {
+		c, ok := commands[d]
+		if !ok {
+			return nil, fmt.Errorf("$BP_TOMCAT_DEFAULT_PROCESS %s is not a process type", d)
+		}
+		commands[DefaultProcess] = c
+	}
+
+	var types []string
+	for k := range commands {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+
+	var p layers.Processes
+	for _, t := range types {
+		c := commands[t]
+		c.Type = t
+		p = append(p, c)
+	}
+
+	return p, nil
+}
+
+func jmx() (bool, error) {
+	s, ok := os.LookupEnv("BP_TOMCAT_JMX")
+	if !ok {
+		return false, nil
+	}
+
+	j, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("$BP_TOMCAT_JMX must be a boolean: %w", err)
+	}
+
+	return j, nil
+}
+
+func configuredProcesses() map[string]string {
+	p := make(map[string]string)
+
+	for _, e := range os.Environ() {
+		s := strings.SplitN(e, "=", 2)
+		if len(s) != 2 || !strings.HasPrefix(s[0], "BP_TOMCAT_PROCESS_") {
+			continue
+		}
+
+		t := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(s[0], "BP_TOMCAT_PROCESS_"), "_", "-"))
+		p[t] = s[1]
+	}
+
+	return p
+}
+
+func plannedProcesses(plans []buildpackplan.Plan) (map[string]string, error) {
+	p := make(map[string]string)
+
+	for _, plan := range plans {
+		m, ok := plan.Metadata[ProcessesMetadata]
+		if !ok {
+			continue
+		}
+
+		processes, ok := m.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s metadata must be a table of process type to JVM options", ProcessesMetadata)
+		}
+
+		for k, v := range processes {
+			o, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("JVM options for process type %s must be a string", k)
+			}
+
+			if existing, ok := p[k]; ok && existing != o {
+				return nil, fmt.Errorf("process type %s requested with conflicting JVM options: %q and %q", k, existing, o)
+			}
+			p[k] = o
+		}
+	}
+
+	return p, nil
+}
+
+func isReserved(t string) bool {
+	for _, r := range reserved {
+		if r == t {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package home_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpackplan"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/home"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	itest "github.com/cloudfoundry/tomcat-cnb/internal/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestProcesses(t *testing.T) {
+	spec.Run(t, "Processes", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var f *test.BuildFactory
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+			test.TouchFile(t, f.Build.Buildpack.Root, "bin", "tomcat-launcher")
+			f.AddDependency("tomcat", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+		})
+
+		processes := func() layers.Processes {
+			h, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(h.Contribute()).To(gomega.Succeed())
+
+			var m layers.Metadata
+			_, err = toml.DecodeFile(filepath.Join(f.Build.Layers.Root, "launch.toml"), &m)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			return m.Processes
+		}
+
+		process := func(p layers.Processes, t string) layers.Process {
+			for _, q := range p {
+				if q.Type == t {
+					return q
+				}
+			}
+
+			return layers.Process{}
+		}
+
+		command := func(p layers.Processes, t string) string {
+			return process(p, t).Command
+		}
+
+		it("uses $BP_TOMCAT_COMMAND", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_COMMAND", "catalina.sh run")()
+
+			p := processes()
+			g.Expect(command(p, "web")).To(gomega.Equal("catalina.sh run"))
+			g.Expect(command(p, "web-debug")).To(gomega.HaveSuffix(" catalina.sh run"))
+			g.Expect(process(p, "web").Direct).To(gomega.BeFalse())
+		})
+
+		it("does not contribute web-jmx by default", func() {
+			g.Expect(command(processes(), home.JMXProcess)).To(gomega.BeEmpty())
+		})
+
+		it("contributes web-jmx with $BP_TOMCAT_JMX", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_JMX", "true")()
+
+			g.Expect(process(processes(), home.JMXProcess).Args).To(gomega.Equal([]string{home.JMXOptions}))
+		})
+
+		it("fails with invalid $BP_TOMCAT_JMX", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_JMX", "test-value")()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError(gomega.HavePrefix("$BP_TOMCAT_JMX must be a boolean")))
+		})
+
+		it("uses $BP_TOMCAT_DEFAULT_PROCESS for web", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_DEFAULT_PROCESS", "web-debug")()
+
+			p := processes()
+			g.Expect(process(p, "web").Args).To(gomega.Equal(process(p, "web-debug").Args))
+			g.Expect(process(p, "tomcat").Args).To(gomega.BeEmpty())
+		})
+
+		it("fails with unknown $BP_TOMCAT_DEFAULT_PROCESS", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_DEFAULT_PROCESS", "web-unknown")()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_DEFAULT_PROCESS web-unknown is not a process type"))
+		})
+
+		it("contributes $BP_TOMCAT_PROCESS_<TYPE>", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_PROCESS_WEB_PROFILE", "-Dtest-key=test-value")()
+
+			g.Expect(process(processes(), "web-profile")).To(gomega.Equal(layers.Process{
+				Type: "web-profile", Command: "tomcat-launcher", Args: []string{"-Dtest-key=test-value"}, Direct: true,
+			}))
+		})
+
+		it("fails with reserved $BP_TOMCAT_PROCESS_<TYPE>", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_PROCESS_TASK", "-Dtest-key=test-value")()
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError("process type task is reserved"))
+		})
+
+		it("contributes build plan process types", func() {
+			f.AddPlan(buildpackplan.Plan{
+				Name:     "tomcat",
+				Metadata: buildpackplan.Metadata{home.ProcessesMetadata: map[string]interface{}{"web-agent": "-javaagent:agent.jar"}},
+			})
+
+			g.Expect(process(processes(), "web-agent")).To(gomega.Equal(layers.Process{
+				Type: "web-agent", Command: "tomcat-launcher", Args: []string{"-javaagent:agent.jar"}, Direct: true,
+			}))
+		})
+
+		it("prefers $BP_TOMCAT_PROCESS_<TYPE> to build plan", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_PROCESS_WEB_AGENT", "-javaagent:other.jar")()
+			f.AddPlan(buildpackplan.Plan{
+				Name:     "tomcat",
+				Metadata: buildpackplan.Metadata{home.ProcessesMetadata: map[string]interface{}{"web-agent": "-javaagent:agent.jar"}},
+			})
+
+			g.Expect(process(processes(), "web-agent")).To(gomega.Equal(layers.Process{
+				Type: "web-agent", Command: "tomcat-launcher", Args: []string{"-javaagent:other.jar"}, Direct: true,
+			}))
+		})
+
+		it("fails with conflicting build plan process types", func() {
+			f.AddPlan(buildpackplan.Plan{
+				Name:     "tomcat",
+				Metadata: buildpackplan.Metadata{home.ProcessesMetadata: map[string]interface{}{"web-agent": "-javaagent:agent.jar"}},
+			})
+			f.AddPlan(buildpackplan.Plan{
+				Name:     "tomcat",
+				Metadata: buildpackplan.Metadata{home.ProcessesMetadata: map[string]interface{}{"web-agent": "-javaagent:other.jar"}},
+			})
+
+			_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+			g.Expect(err).To(gomega.MatchError(
+				`process type web-agent requested with conflicting JVM options: "-javaagent:agent.jar" and "-javaagent:other.jar"`))
+		})
+	}, spec.Report(report.Terminal{}))
+}
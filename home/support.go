@@ -0,0 +1,91 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package home
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+)
+
+const (
+	// EOLMetadata is the key, in a dependency's buildpack.toml entry, of the date that upstream support ends.
+	EOLMetadata = "eol"
+
+	// StrictEOLPolicy fails the build if the selected version of Tomcat is past end of life.
+	StrictEOLPolicy = "strict"
+
+	// WarnEOLPolicy warns if the selected version of Tomcat is past end of life.
+	WarnEOLPolicy = "warn"
+)
+
+// support warns if the selected version of Tomcat is past end of life, or fails if $BP_TOMCAT_EOL_POLICY is strict.
+// It also warns if a newer patch of the same minor version is available in the buildpack but was not selected.
+func support(build build.Build, deps buildpack.Dependencies, dependency buildpack.Dependency) error {
+	policy := WarnEOLPolicy
+	if p, ok := os.LookupEnv("BP_TOMCAT_EOL_POLICY"); ok {
+		if p != StrictEOLPolicy && p != WarnEOLPolicy {
+			return fmt.Errorf("$BP_TOMCAT_EOL_POLICY must be %s or %s, found %s", StrictEOLPolicy, WarnEOLPolicy, p)
+		}
+		policy = p
+	}
+
+	eol, ok, err := endOfLife(build.Buildpack, dependency)
+	if err != nil {
+		return err
+	}
+
+	if ok && !time.Now().Before(eol) {
+		if policy == StrictEOLPolicy {
+			return fmt.Errorf("%s %s reached end of life on %s", dependency.Name, dependency.Version.Original(),
+				eol.Format("2006-01-02"))
+		}
+
+		build.Logger.HeaderWarning("%s %s reached end of life on %s and no longer receives security fixes",
+			dependency.Name, dependency.Version.Original(), eol.Format("2006-01-02"))
+	}
+
+	v := dependency.Version
+	if latest, err := deps.Best(dependency.ID, fmt.Sprintf("%d.%d.*", v.Major(), v.Minor()), build.Stack); err == nil &&
+		latest.Version.GreaterThan(v.Version) {
+
+		build.Logger.HeaderWarning("%s %s is selected, but %s is available.  Update the requested version to receive the latest patches.",
+			dependency.Name, v.Original(), latest.Version.Original())
+	}
+
+	return nil
+}
+
+func endOfLife(b buildpack.Buildpack, dependency buildpack.Dependency) (time.Time, bool, error) {
+	switch e := entry(b, dependency)[EOLMetadata].(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return e, true, nil
+	case string:
+		t, err := time.Parse("2006-01-02", e)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s for %s %s: %w", EOLMetadata, dependency.ID, dependency.Version.Original(), err)
+		}
+		return t, true, nil
+	default:
+		return time.Time{}, false, fmt.Errorf("invalid %s for %s %s: %v", EOLMetadata, dependency.ID, dependency.Version.Original(), e)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package home_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	bplogger "github.com/buildpacks/libbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/home"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	itest "github.com/cloudfoundry/tomcat-cnb/internal/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSupport(t *testing.T) {
+	spec.Run(t, "Support", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			b bytes.Buffer
+			f *test.BuildFactory
+		)
+
+		eol := func(version string, date string) {
+			for _, d := range f.Build.Buildpack.Metadata[buildpack.DependenciesMetadata].([]map[string]interface{}) {
+				if d["version"] == version {
+					d[home.EOLMetadata] = date
+				}
+			}
+		}
+
+		it.Before(func() {
+			b.Reset()
+
+			f = test.NewBuildFactory(t)
+			f.Build.Logger = logger.Logger{Logger: bplogger.NewLogger(nil, &b)}
+			f.AddDependencyWithVersion("tomcat", "9.0.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+			itest.SignDependencies(t, f)
+		})
+
+		when("end of life", func() {
+
+			it("does not warn before end of life", func() {
+				eol("9.0.0", "2999-01-01")
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.String()).NotTo(gomega.ContainSubstring("end of life"))
+			})
+
+			it("warns after end of life", func() {
+				eol("9.0.0", "2000-01-01")
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.String()).To(gomega.ContainSubstring("9.0.0 reached end of life on 2000-01-01"))
+			})
+
+			it("fails after end of life with $BP_TOMCAT_EOL_POLICY=strict", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_EOL_POLICY", "strict")()
+				eol("9.0.0", "2000-01-01")
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("stub-tomcat.tar.gz 9.0.0 reached end of life on 2000-01-01"))
+			})
+
+			it("fails with invalid $BP_TOMCAT_EOL_POLICY", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_EOL_POLICY", "test-policy")()
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_EOL_POLICY must be strict or warn, found test-policy"))
+			})
+
+			it("fails with invalid end of life", func() {
+				eol("9.0.0", "test-date")
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).To(gomega.MatchError(gomega.HavePrefix("invalid eol for tomcat 9.0.0")))
+			})
+		})
+
+		when("newer patch", func() {
+
+			it.Before(func() {
+				f.AddDependencyWithVersion("tomcat", "9.0.1", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+				f.AddDependencyWithVersion("tomcat", "9.1.0", filepath.Join("testdata", "stub-tomcat.tar.gz"))
+				itest.SignDependencies(t, f)
+			})
+
+			it("warns when newer patch is available", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "9.0.0")()
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.String()).To(gomega.ContainSubstring("9.0.0 is selected, but 9.0.1 is available"))
+			})
+
+			it("does not warn with latest patch", func() {
+				defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "9.0.*")()
+
+				_, err := home.NewHome(f.Build, internal.UnknownNamespace)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(b.String()).NotTo(gomega.ContainSubstring("is available"))
+			})
+		})
+	}, spec.Report(report.Terminal{}))
+}
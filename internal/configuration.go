@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// InsertConfiguration inserts content into a Tomcat configuration file, at the start of the line containing the first
+// occurrence of anchor.  The content is delimited by comments naming the contributor, so that inserting content for
+// the same contributor again replaces, rather than duplicates, the earlier content.
+func InsertConfiguration(file string, contributor string, anchor string, content string) error {
+	i, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	begin := fmt.Sprintf("<!-- BEGIN %s -->\n", contributor)
+	end := fmt.Sprintf("<!-- END %s -->\n", contributor)
+
+	s := string(b)
+	if start := strings.Index(s, begin); start >= 0 {
+		if stop := strings.Index(s[start:], end); stop >= 0 {
+			s = s[:start] + s[start+stop+len(end):]
+		}
+	}
+
+	a := strings.Index(s, anchor)
+	if a < 0 {
+		return fmt.Errorf("unable to find %s in %s", anchor, file)
+	}
+	a = strings.LastIndex(s[:a], "\n") + 1
+
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	s = s[:a] + begin + content + end + s[a:]
+	return ioutil.WriteFile(file, []byte(s), i.Mode())
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestConfiguration(t *testing.T) {
+	spec.Run(t, "Configuration", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var file string
+
+		it.Before(func() {
+			file = filepath.Join(test.ScratchDir(t, "configuration"), "context.xml")
+			test.WriteFileWithPerm(t, file, 0640, `<Context>
+    <Resources allowLinking="true"/>
+</Context>
+`)
+		})
+
+		it("inserts content before anchor", func() {
+			g.Expect(internal.InsertConfiguration(file, "test-contributor", "</Context>", `    <Manager/>`)).To(gomega.Succeed())
+
+			g.Expect(file).To(test.HavePermissions(0640))
+			g.Expect(file).To(test.HaveContent(`<Context>
+    <Resources allowLinking="true"/>
+<!-- BEGIN test-contributor -->
+    <Manager/>
+<!-- END test-contributor -->
+</Context>
+`))
+		})
+
+		it("replaces previously inserted content", func() {
+			g.Expect(internal.InsertConfiguration(file, "test-contributor", "</Context>", `    <Manager/>`)).To(gomega.Succeed())
+			g.Expect(internal.InsertConfiguration(file, "test-contributor", "</Context>", `    <Valve/>`)).To(gomega.Succeed())
+
+			g.Expect(file).To(test.HaveContent(`<Context>
+    <Resources allowLinking="true"/>
+<!-- BEGIN test-contributor -->
+    <Valve/>
+<!-- END test-contributor -->
+</Context>
+`))
+		})
+
+		it("fails without anchor", func() {
+			g.Expect(internal.InsertConfiguration(file, "test-contributor", "</Server>", `    <Manager/>`)).
+				To(gomega.MatchError(fmt.Sprintf("unable to find </Server> in %s", file)))
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+)
+
+// Dependencies returns the buildpack's dependencies, with any user supplied overrides.  URIs are not rewritten to
+// mirrors, which is done only when a dependency is downloaded.
+func Dependencies(build build.Build) (buildpack.Dependencies, error) {
+	deps, err := build.Buildpack.Dependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	return Override(deps, build.Buildpack.Metadata, build.Stack)
+}
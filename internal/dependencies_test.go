@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestDependencies(t *testing.T) {
+	spec.Run(t, "Dependencies", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var f *test.BuildFactory
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+			f.AddDependency("test-id", "dependencies_test.go")
+		})
+
+		it("returns buildpack dependencies", func() {
+			deps, err := internal.Dependencies(f.Build)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(deps).To(gomega.HaveLen(1))
+			g.Expect(deps[0].URI).To(gomega.Equal("https://localhost/dependencies_test.go"))
+		})
+
+		it("does not rewrite buildpack dependencies to mirror", func() {
+			defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR", "https://mirror.example.com")()
+
+			deps, err := internal.Dependencies(f.Build)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(deps[0].URI).To(gomega.Equal("https://localhost/dependencies_test.go"))
+		})
+
+		it("does not rewrite overridden dependencies to mirror", func() {
+			defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR", "https://mirror.example.com")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TEST_ID_VERSION", "2.0.0")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TEST_ID_URI", "https://example.com/test-override.jar")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TEST_ID_SHA256", "test-sha256")()
+
+			deps, err := internal.Dependencies(f.Build)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(deps).To(gomega.HaveLen(1))
+			g.Expect(deps[0].URI).To(gomega.Equal("https://example.com/test-override.jar"))
+		})
+	}, spec.Report(report.Terminal{}))
+}
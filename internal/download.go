@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	bplayers "github.com/buildpacks/libbuildpack/v2/layers"
+	bplogger "github.com/buildpacks/libbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+)
+
+// DownloadConcurrency is the maximum number of dependencies that are downloaded at the same time.
+const DownloadConcurrency = 4
+
+// DownloadErrors are the errors of the dependencies that failed to download.
+type DownloadErrors []error
+
+func (d DownloadErrors) Error() string {
+	var s []string
+	for _, e := range d {
+		s = append(s, e.Error())
+	}
+
+	return fmt.Sprintf("unable to download %d dependencies: %s", len(d), strings.Join(s, "; "))
+}
+
+// Download downloads the artifacts of dependencies concurrently, so that the layers that use them find them already
+// downloaded.  The log output of each download is buffered and written in the order of the dependencies once every
+// download has completed.  Every download is attempted, and the errors of those that fail are returned together.
+// Dependencies with the same artifact share a layer, so they are downloaded one after another.  Each download has its
+// own layers, so that its output is buffered, and the layers it touched are registered with the build's layers once it
+// completes.
+func Download(build build.Build, dependencies []buildpack.Dependency) error {
+	var unique []buildpack.Dependency
+	for _, d := range dependencies {
+		if !containsDependency(unique, d) {
+			unique = append(unique, d)
+		}
+	}
+
+	if len(unique) == 0 {
+		return nil
+	}
+
+	mirrors := NewMirrors(build)
+
+	var (
+		errs    = make([]error, len(unique))
+		outputs = make([]bytes.Buffer, len(unique))
+		started = make(map[string]bool)
+		tokens  = make(chan struct{}, DownloadConcurrency)
+		wg      sync.WaitGroup
+	)
+
+	build.Logger.Header("Downloading %d dependencies, %d at a time", len(unique), DownloadConcurrency)
+
+	for _, d := range unique {
+		if started[d.SHA256] {
+			continue
+		}
+		started[d.SHA256] = true
+
+		wg.Add(1)
+
+		go func(sha256 string) {
+			defer wg.Done()
+
+			tokens <- struct{}{}
+			defer func() { <-tokens }()
+
+			for i, d := range unique {
+				if d.SHA256 != sha256 {
+					continue
+				}
+
+				var debug io.Writer
+				if build.Logger.IsDebugEnabled() {
+					debug = &outputs[i]
+				}
+
+				l := logger.Logger{Logger: bplogger.NewLogger(debug, &outputs[i])}
+				cache := bplayers.NewLayers(build.Buildpack.CacheRoot, l.Logger)
+				ls := layers.NewLayers(bplayers.NewLayers(build.Layers.Root, l.Logger), cache, build.Buildpack, l)
+
+				if err := download(ls, cache, mirrors, build.Buildpack.Info, d); err != nil {
+					errs[i] = fmt.Errorf("%s %s: %w", d.ID, d.Version.Original(), err)
+				}
+			}
+		}(d.SHA256)
+	}
+
+	wg.Wait()
+
+	var failed DownloadErrors
+	for i, d := range unique {
+		build.Logger.Body("%s %s", d.Name, d.Version.Original())
+
+		if s := strings.TrimSuffix(outputs[i].String(), "\n"); s != "" {
+			build.Logger.Info("%s", s)
+		}
+
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+		} else {
+			build.Layers.Layer(d.SHA256).Touch()
+		}
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
+// download downloads the artifact of a dependency, from its mirror if it has one.  An artifact already in the buildpack
+// cache or the download layer is not downloaded again, whichever mirror is used.
+func download(ls layers.Layers, cache bplayers.Layers, mirrors Mirrors, info buildpack.Info, dependency buildpack.Dependency) error {
+	m, err := mirrors.Rewrite(dependency)
+	if err != nil {
+		return err
+	}
+
+	l := ls.Layer(dependency.SHA256)
+
+	ok, err := l.MetadataMatches(dependency)
+	if err != nil {
+		return err
+	}
+
+	if ok || m.URI == dependency.URI || cached(cache.Layer(dependency.SHA256), dependency) {
+		_, err := ls.DownloadLayer(dependency).Artifact()
+		return err
+	}
+
+	return mirrors.Download(l, dependency, m.URI, info)
+}
+
+// cached returns whether the buildpack cache has the artifact of a dependency.
+func cached(layer bplayers.Layer, dependency buildpack.Dependency) bool {
+	var d buildpack.Dependency
+	if err := layer.ReadMetadata(&d); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(d, dependency)
+}
+
+func containsDependency(dependencies []buildpack.Dependency, dependency buildpack.Dependency) bool {
+	for _, d := range dependencies {
+		if d.ID == dependency.ID && d.Version.Original() == dependency.Version.Original() && d.SHA256 == dependency.SHA256 {
+			return true
+		}
+	}
+
+	return false
+}
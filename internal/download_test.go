@@ -0,0 +1,225 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	bplogger "github.com/buildpacks/libbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestDownload(t *testing.T) {
+	spec.Run(t, "Download", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			b       bytes.Buffer
+			f       *test.BuildFactory
+			m       sync.Mutex
+			active  int
+			maximum int
+			server  *httptest.Server
+
+			authorization string
+			header        bool
+			requests      int
+		)
+
+		dependency := func(id string, content string) buildpack.Dependency {
+			s := sha256.Sum256([]byte(content))
+
+			return buildpack.Dependency{
+				ID:      id,
+				Name:    fmt.Sprintf("Test %s", id),
+				Version: buildpack.Version{Version: semver.MustParse("1.0.0")},
+				URI:     fmt.Sprintf("%s/%s/%s.jar", server.URL, content, id),
+				SHA256:  hex.EncodeToString(s[:]),
+				Stacks:  buildpack.Stacks{f.Build.Stack},
+			}
+		}
+
+		it.Before(func() {
+			b.Reset()
+			active, maximum, authorization, header, requests = 0, 0, "", false, 0
+
+			f = test.NewBuildFactory(t)
+			f.Build.Logger = logger.Logger{Logger: bplogger.NewLogger(nil, &b)}
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				m.Lock()
+				active++
+				if active > maximum {
+					maximum = active
+				}
+				requests++
+				header = strings.Contains(b.String(), "Downloading")
+				m.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				m.Lock()
+				active--
+				m.Unlock()
+
+				if u, p, ok := r.BasicAuth(); ok {
+					m.Lock()
+					authorization = fmt.Sprintf("%s:%s", u, p)
+					m.Unlock()
+				}
+
+				if filepath.Base(filepath.Dir(r.URL.Path)) == "missing" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				_, _ = fmt.Fprint(w, filepath.Base(filepath.Dir(r.URL.Path)))
+			}))
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("downloads dependencies with bounded concurrency", func() {
+			var d []buildpack.Dependency
+			for i := 0; i < internal.DownloadConcurrency*2; i++ {
+				d = append(d, dependency(fmt.Sprintf("dependency-%d", i), fmt.Sprintf("content-%d", i)))
+			}
+
+			g.Expect(internal.Download(f.Build, d)).To(gomega.Succeed())
+
+			for _, dep := range d {
+				g.Expect(filepath.Join(f.Build.Layers.Layer(dep.SHA256).Root, fmt.Sprintf("%s.jar", dep.ID))).To(gomega.BeARegularFile())
+			}
+			g.Expect(maximum).To(gomega.BeNumerically("<=", internal.DownloadConcurrency))
+		})
+
+		it("logs downloads in order", func() {
+			d := []buildpack.Dependency{dependency("bravo", "content-bravo"), dependency("alpha", "content-alpha")}
+
+			g.Expect(internal.Download(f.Build, d)).To(gomega.Succeed())
+
+			g.Expect(b.String()).To(gomega.MatchRegexp(`(?s)Test bravo 1\.0\.0.*Downloading.*/bravo\.jar.*Test alpha 1\.0\.0.*Downloading.*/alpha\.jar`))
+		})
+
+		it("downloads each artifact once", func() {
+			d := dependency("alpha", "content-alpha")
+
+			g.Expect(internal.Download(f.Build, []buildpack.Dependency{d, d})).To(gomega.Succeed())
+
+			g.Expect(regexp.MustCompile("Test alpha").FindAllString(b.String(), -1)).To(gomega.HaveLen(1))
+		})
+
+		it("logs header before downloading", func() {
+			g.Expect(internal.Download(f.Build, []buildpack.Dependency{dependency("alpha", "content-alpha")})).To(gomega.Succeed())
+
+			g.Expect(header).To(gomega.BeTrue())
+		})
+
+		it("does not download dependencies with the same artifact again once downloaded", func() {
+			alpha := dependency("alpha", "content-alpha")
+			alpha.URI = "https://example.com/content-alpha/alpha.jar"
+			bravo := alpha
+			bravo.ID, bravo.Name = "bravo", "Test bravo"
+
+			defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_EXAMPLE_COM", server.URL)()
+			g.Expect(internal.Download(f.Build, []buildpack.Dependency{alpha, bravo})).To(gomega.Succeed())
+			g.Expect(requests).To(gomega.Equal(2))
+
+			g.Expect(f.Build.Layers.DownloadLayer(bravo).Artifact()).To(gomega.BeARegularFile())
+			g.Expect(requests).To(gomega.Equal(2))
+		})
+
+		it("registers downloaded layers as touched", func() {
+			d := dependency("alpha", "content-alpha")
+
+			g.Expect(internal.Download(f.Build, []buildpack.Dependency{d})).To(gomega.Succeed())
+			g.Expect(f.Build.Layers.TouchedLayers.Cleanup()).To(gomega.Succeed())
+
+			g.Expect(f.Build.Layers.Layer(d.SHA256).Metadata).To(gomega.BeARegularFile())
+		})
+
+		it("downloads from mirror", func() {
+			d := dependency("alpha", "content-alpha")
+			d.URI = "https://example.com/content-alpha/alpha.jar"
+
+			u, err := url.Parse(server.URL)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			u.User = url.UserPassword("test-user", "test-password")
+			defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_EXAMPLE_COM", u.String())()
+
+			g.Expect(internal.Download(f.Build, []buildpack.Dependency{d})).To(gomega.Succeed())
+
+			layer := f.Build.Layers.Layer(d.SHA256)
+			g.Expect(filepath.Join(layer.Root, "alpha.jar")).To(gomega.BeARegularFile())
+			g.Expect(authorization).To(gomega.Equal("test-user:test-password"))
+			g.Expect(b.String()).NotTo(gomega.ContainSubstring("test-password"))
+
+			var m buildpack.Dependency
+			g.Expect(layer.ReadMetadata(&m)).To(gomega.Succeed())
+			g.Expect(m.URI).To(gomega.Equal(d.URI))
+		})
+
+		it("does not download from mirror again", func() {
+			d := dependency("alpha", "content-alpha")
+			d.URI = "https://example.com/content-alpha/alpha.jar"
+
+			defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_EXAMPLE_COM", server.URL)()
+			g.Expect(internal.Download(f.Build, []buildpack.Dependency{d})).To(gomega.Succeed())
+
+			defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_EXAMPLE_COM", "https://other.example.com")()
+			g.Expect(internal.Download(f.Build, []buildpack.Dependency{d})).To(gomega.Succeed())
+
+			g.Expect(regexp.MustCompile("Downloading from").FindAllString(b.String(), -1)).To(gomega.HaveLen(1))
+		})
+
+		it("combines errors of each dependency", func() {
+			invalid := dependency("bravo", "content-bravo")
+			invalid.SHA256 = "invalid-sha256"
+
+			d := []buildpack.Dependency{dependency("alpha", "missing"), invalid, dependency("charlie", "content-charlie")}
+
+			err := internal.Download(f.Build, d)
+			g.Expect(err).To(gomega.MatchError(
+				"unable to download 2 dependencies: alpha 1.0.0: could not download: 404; " +
+					"bravo 1.0.0: dependency sha256 mismatch: expected sha256 invalid-sha256, " +
+					"actual sha256 ee6600df2ccf2c0c6d375ff6ca1d92a893573a6204b3cc37d61879056f5cec20"))
+			g.Expect(filepath.Join(f.Build.Layers.Layer(d[2].SHA256).Root, "charlie.jar")).To(gomega.BeARegularFile())
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+)
+
+// Inputs are the SHA256 hashes of the files, environment variables, and dependencies that a layer is contributed from,
+// keyed by a description of each.  Recorded in the layer metadata, they cause the layer to be contributed again when
+// any of them change.  Environment variables are hashed so that their values are not written to the metadata.
+type Inputs map[string]string
+
+// NewInputs creates a new, empty, Inputs instance.
+func NewInputs() Inputs {
+	return Inputs{}
+}
+
+// AddFile adds the hash of the contents of a file, keyed by its name.
+func (i Inputs) AddFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := sha256.New()
+	if _, err := io.Copy(s, f); err != nil {
+		return err
+	}
+
+	i[filepath.Base(path)] = hex.EncodeToString(s.Sum(nil))
+	return nil
+}
+
+// AddEnvironment adds the hash of the value of each environment variable that is set, keyed by $<NAME>.
+func (i Inputs) AddEnvironment(names ...string) {
+	for _, n := range names {
+		if v, ok := os.LookupEnv(n); ok {
+			s := sha256.Sum256([]byte(v))
+			i[fmt.Sprintf("$%s", n)] = hex.EncodeToString(s[:])
+		}
+	}
+}
+
+// AddDependency adds the hash of a dependency, keyed by its id.
+func (i Inputs) AddDependency(dependency buildpack.Dependency) {
+	i[dependency.ID] = dependency.SHA256
+}
+
+// Changes returns descriptions, in order, of the inputs that differ from those the layer was last contributed from.
+// No changes are returned if the layer has not been contributed before.
+func (i Inputs) Changes(layer layers.Layer) ([]string, error) {
+	var m struct {
+		Inputs Inputs `toml:"inputs"`
+	}
+
+	if err := layer.ReadMetadata(&m); err != nil {
+		return nil, err
+	}
+
+	if m.Inputs == nil {
+		return nil, nil
+	}
+
+	var keys []string
+	for k := range i {
+		keys = append(keys, k)
+	}
+	for k := range m.Inputs {
+		if _, ok := i[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []string
+	for _, k := range keys {
+		previous, wasOk := m.Inputs[k]
+		current, isOk := i[k]
+
+		switch {
+		case !wasOk:
+			changes = append(changes, fmt.Sprintf("%s added", k))
+		case !isOk:
+			changes = append(changes, fmt.Sprintf("%s removed", k))
+		case previous != current:
+			changes = append(changes, fmt.Sprintf("%s changed", k))
+		}
+	}
+
+	return changes, nil
+}
+
+// Contribute contributes a layer, as layers.Layer.Contribute, logging which of its inputs changed since it was last
+// contributed.
+func Contribute(layer layers.Layer, expected logger.Identifiable, inputs Inputs, contributor layers.LayerContributor, flags ...layers.Flag) error {
+	changes, err := inputs.Changes(layer)
+	if err != nil {
+		return err
+	}
+
+	return layer.Contribute(expected, func(layer layers.Layer) error {
+		if len(changes) > 0 {
+			layer.Logger.Body("Contributing again because %s", strings.Join(changes, ", "))
+		}
+
+		return contributor(layer)
+	}, flags...)
+}
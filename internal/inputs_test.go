@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	bplayers "github.com/buildpacks/libbuildpack/v2/layers"
+	bplogger "github.com/buildpacks/libbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+	"github.com/cloudfoundry/libcfbuildpack/v2/logger"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestInputs(t *testing.T) {
+	spec.Run(t, "Inputs", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			b     bytes.Buffer
+			layer layers.Layer
+			root  string
+		)
+
+		it.Before(func() {
+			b.Reset()
+
+			root = test.ScratchDir(t, "inputs")
+			l := logger.Logger{Logger: bplogger.NewLogger(nil, &b)}
+			ls := layers.NewLayers(bplayers.NewLayers(filepath.Join(root, "layers"), l.Logger), bplayers.NewLayers(filepath.Join(root, "cache"), l.Logger), buildpack.Buildpack{}, l)
+			layer = ls.Layer("test-layer")
+		})
+
+		it("hashes file contents", func() {
+			test.WriteFile(t, filepath.Join(root, "test-file"), "test-content")
+
+			i := internal.NewInputs()
+			g.Expect(i.AddFile(filepath.Join(root, "test-file"))).To(gomega.Succeed())
+
+			g.Expect(i).To(gomega.Equal(internal.Inputs{"test-file": "0a3666a0710c08aa6d0de92ce72beeb5b93124cce1bf3701c9d6cdeb543cb73e"}))
+		})
+
+		it("hashes set environment variables", func() {
+			defer test.ReplaceEnv(t, "TEST_KEY", "test-value")()
+
+			i := internal.NewInputs()
+			i.AddEnvironment("TEST_KEY", "TEST_UNSET_KEY")
+
+			g.Expect(i).To(gomega.Equal(internal.Inputs{"$TEST_KEY": "5b1406fffc9de5537eb35a845c99521f26fba0e772d58b42e09f4221b9e043ae"}))
+		})
+
+		it("uses dependency hashes", func() {
+			i := internal.NewInputs()
+			i.AddDependency(buildpack.Dependency{ID: "test-id", SHA256: "test-sha256"})
+
+			g.Expect(i).To(gomega.Equal(internal.Inputs{"test-id": "test-sha256"}))
+		})
+
+		it("returns no changes for a new layer", func() {
+			g.Expect(internal.Inputs{"alpha": "1"}.Changes(layer)).To(gomega.BeEmpty())
+		})
+
+		it("returns changes", func() {
+			g.Expect(layer.WriteMetadata(marker{internal.Inputs{"alpha": "1", "bravo": "2", "charlie": "3"}})).To(gomega.Succeed())
+
+			g.Expect(internal.Inputs{"alpha": "1", "bravo": "4", "delta": "5"}.Changes(layer)).
+				To(gomega.Equal([]string{"bravo changed", "charlie removed", "delta added"}))
+		})
+
+		it("logs changes when contributing", func() {
+			g.Expect(layer.WriteMetadata(marker{internal.Inputs{"alpha": "1"}})).To(gomega.Succeed())
+
+			i := internal.Inputs{"alpha": "2"}
+			g.Expect(internal.Contribute(layer, marker{i}, i, func(layer layers.Layer) error {
+				return nil
+			})).To(gomega.Succeed())
+
+			g.Expect(b.String()).To(gomega.ContainSubstring("Contributing again because alpha changed"))
+		})
+
+		it("does not contribute with unchanged inputs", func() {
+			i := internal.Inputs{"alpha": "1"}
+			g.Expect(layer.WriteMetadata(marker{i})).To(gomega.Succeed())
+
+			g.Expect(internal.Contribute(layer, marker{i}, i, func(layer layers.Layer) error {
+				t.Fatal("contributed layer with unchanged inputs")
+				return nil
+			})).To(gomega.Succeed())
+		})
+	}, spec.Report(report.Terminal{}))
+}
+
+type marker struct {
+	Inputs internal.Inputs `toml:"inputs"`
+}
+
+func (marker) Identity() (string, string) {
+	return "Test", ""
+}
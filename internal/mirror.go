@@ -0,0 +1,195 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/build"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/layers"
+)
+
+const (
+	// DefaultMirror is the key, in a mirror service binding's credentials, of the mirror for every host.
+	DefaultMirror = "default"
+
+	// MirrorService is the filter used to find the dependency mirror service binding.
+	MirrorService = "dependency-mirror"
+
+	// OriginalHost is replaced, in a mirror, with the host of the URI being rewritten.
+	OriginalHost = "{originalHost}"
+)
+
+// Mirrors rewrite dependency URIs so that dependencies are downloaded from a mirror rather than their origin.  The
+// SHA256 of a dependency is unchanged, so an artifact from a mirror is verified exactly as one from its origin.  Only
+// the download uses the rewritten URI, every layer records the original.
+type Mirrors struct {
+	// Default is the mirror for hosts without a more specific mirror.
+	Default string
+
+	// Hosts are the mirrors for individual hosts, keyed by lower-cased host name with "_" in place of "." and "-".
+	Hosts map[string]string
+
+	// Prefixes are the mirrors for URIs starting with a prefix, keyed by prefix.
+	Prefixes map[string]string
+}
+
+// NewMirrors creates a new Mirrors instance from a service binding matching MirrorService and from environment
+// variables, which take precedence:
+//
+// $BP_DEPENDENCY_MIRROR: the mirror for every host
+// $BP_DEPENDENCY_MIRROR_<HOST>: the mirror for a host, upper-cased with "_" in place of "." and "-"
+//
+// The keys of the binding's credentials are DefaultMirror, a host, or a URI prefix.
+func NewMirrors(build build.Build) Mirrors {
+	m := Mirrors{Hosts: make(map[string]string), Prefixes: make(map[string]string)}
+
+	if c, ok := build.Services.FindServiceCredentials(MirrorService); ok {
+		for k, v := range c {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case k == DefaultMirror:
+				m.Default = s
+			case strings.Contains(k, "://"):
+				m.Prefixes[k] = s
+			default:
+				m.Hosts[hostKey(k)] = s
+			}
+		}
+	}
+
+	for _, e := range os.Environ() {
+		s := strings.SplitN(e, "=", 2)
+		if len(s) != 2 {
+			continue
+		}
+
+		if s[0] == "BP_DEPENDENCY_MIRROR" {
+			m.Default = s[1]
+		} else if strings.HasPrefix(s[0], "BP_DEPENDENCY_MIRROR_") {
+			h := strings.TrimPrefix(s[0], "BP_DEPENDENCY_MIRROR_")
+			m.Hosts[hostKey(h)] = s[1]
+		}
+	}
+
+	return m
+}
+
+// Rewrite returns a dependency with its URI rewritten to the most specific mirror: the longest matching prefix, then
+// the mirror for its host, then the default mirror.
+func (m Mirrors) Rewrite(dependency buildpack.Dependency) (buildpack.Dependency, error) {
+	var prefixes []string
+	for p := range m.Prefixes {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	for _, p := range prefixes {
+		if strings.HasPrefix(dependency.URI, p) {
+			dependency.URI = strings.TrimSuffix(m.Prefixes[p], "/") + "/" + strings.TrimPrefix(dependency.URI[len(p):], "/")
+			return dependency, nil
+		}
+	}
+
+	u, err := url.Parse(dependency.URI)
+	if err != nil {
+		return buildpack.Dependency{}, fmt.Errorf("unable to parse URI of %s %s: %w", dependency.ID, dependency.Version.Original(), err)
+	}
+
+	mirror, ok := m.Hosts[hostKey(u.Hostname())]
+	if !ok {
+		mirror, ok = m.Default, m.Default != ""
+	}
+	if !ok || u.Host == "" {
+		return dependency, nil
+	}
+
+	mirror = strings.ReplaceAll(mirror, OriginalHost, u.Hostname())
+	dependency.URI = strings.TrimSuffix(mirror, "/") + "/" + strings.TrimPrefix(u.RequestURI(), "/")
+	return dependency, nil
+}
+
+// Download downloads the artifact of a dependency from uri, its mirror, to layer.  The artifact is verified against the
+// dependency's SHA256 and keeps the name from the dependency's URI.  The dependency, with its original URI, is written
+// to the layer's metadata, so that a mirror URI, which may contain credentials, is not persisted and a change of mirror
+// does not invalidate the layer.
+func (Mirrors) Download(layer layers.Layer, dependency buildpack.Dependency, uri string, info buildpack.Info) error {
+	if err := os.RemoveAll(layer.Root); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("unable to parse mirror URI of %s %s: %w", dependency.ID, dependency.Version.Original(), err)
+	}
+
+	layer.Logger.Body("Downloading from %s", u.Redacted())
+
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s", info.ID, info.Version))
+
+	t := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+
+	resp, err := (&http.Client{Transport: t}).Do(req)
+	if err != nil {
+		return fmt.Errorf("could not download from %s: %w", u.Redacted(), errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("could not download: %d", resp.StatusCode)
+	}
+
+	s := sha256.New()
+	if err := helper.WriteFileFromReader(filepath.Join(layer.Root, filepath.Base(dependency.URI)), 0644,
+		io.TeeReader(resp.Body, s)); err != nil {
+		return err
+	}
+
+	layer.Logger.Body("Verifying checksum")
+	if a := hex.EncodeToString(s.Sum(nil)); a != dependency.SHA256 {
+		return fmt.Errorf("dependency sha256 mismatch: expected sha256 %s, actual sha256 %s", dependency.SHA256, a)
+	}
+
+	return layer.WriteMetadata(dependency)
+}
+
+// hostKey returns the form a host takes in $BP_DEPENDENCY_MIRROR_<HOST>, lower-cased, so that hosts from environment
+// variables and service bindings are compared alike.
+func hostKey(host string) string {
+	return strings.ToLower(strings.NewReplacer(".", "_", "-", "_").Replace(host))
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/services"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestMirrors(t *testing.T) {
+	spec.Run(t, "Mirrors", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var f *test.BuildFactory
+
+		dependency := buildpack.Dependency{
+			ID:     "test-id",
+			URI:    "https://archive.apache.org/dist/tomcat/test-artifact.tar.gz",
+			SHA256: "test-sha256",
+		}
+
+		it.Before(func() {
+			f = test.NewBuildFactory(t)
+		})
+
+		it("does not rewrite without mirrors", func() {
+			g.Expect(internal.NewMirrors(f.Build).Rewrite(dependency)).To(gomega.Equal(dependency))
+		})
+
+		when("environment variables", func() {
+
+			it("rewrites with $BP_DEPENDENCY_MIRROR", func() {
+				defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR", "https://mirror.example.com/{originalHost}/")()
+
+				d, err := internal.NewMirrors(f.Build).Rewrite(dependency)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(d.URI).To(gomega.Equal("https://mirror.example.com/archive.apache.org/dist/tomcat/test-artifact.tar.gz"))
+				g.Expect(d.SHA256).To(gomega.Equal("test-sha256"))
+			})
+
+			it("prefers $BP_DEPENDENCY_MIRROR_<HOST>", func() {
+				defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR", "https://mirror.example.com")()
+				defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_ARCHIVE_APACHE_ORG", "https://apache.example.com/remote")()
+
+				d, err := internal.NewMirrors(f.Build).Rewrite(dependency)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				g.Expect(d.URI).To(gomega.Equal("https://apache.example.com/remote/dist/tomcat/test-artifact.tar.gz"))
+			})
+
+			it("does not rewrite other hosts", func() {
+				defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_REPO_SPRING_IO", "https://spring.example.com")()
+
+				g.Expect(internal.NewMirrors(f.Build).Rewrite(dependency)).To(gomega.Equal(dependency))
+			})
+		})
+
+		when("service binding", func() {
+
+			it("rewrites by prefix, host, and default", func() {
+				f.AddService("mirror", services.Credentials{
+					"default":                               "https://mirror.example.com",
+					"repo.spring.io":                        "https://spring.example.com",
+					"https://archive.apache.org/dist":       "https://apache.example.com/dist",
+					"https://archive.apache.org/dist/other": "https://other.example.com",
+				}, "dependency-mirror")
+
+				m := internal.NewMirrors(f.Build)
+
+				d, err := m.Rewrite(dependency)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(d.URI).To(gomega.Equal("https://apache.example.com/dist/tomcat/test-artifact.tar.gz"))
+
+				d, err = m.Rewrite(buildpack.Dependency{URI: "https://repo.spring.io/release/test-artifact.jar"})
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(d.URI).To(gomega.Equal("https://spring.example.com/release/test-artifact.jar"))
+
+				d, err = m.Rewrite(buildpack.Dependency{URI: "https://github.com/test-artifact.tar.gz"})
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(d.URI).To(gomega.Equal("https://mirror.example.com/test-artifact.tar.gz"))
+			})
+
+			it("prefers environment variables", func() {
+				defer test.ReplaceEnv(t, "BP_DEPENDENCY_MIRROR_ARCHIVE_APACHE_ORG", "https://apache.example.com")()
+				f.AddService("mirror", services.Credentials{"archive.apache.org": "https://other.example.com"}, "dependency-mirror")
+
+				d, err := internal.NewMirrors(f.Build).Rewrite(dependency)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(d.URI).To(gomega.Equal("https://apache.example.com/dist/tomcat/test-artifact.tar.gz"))
+			})
+		})
+	}, spec.Report(report.Terminal{}))
+}
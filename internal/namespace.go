@@ -0,0 +1,240 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Namespace is the package namespace of the Servlet API that a web application is compiled against.
+type Namespace string
+
+const (
+	// Jakarta is the jakarta.servlet namespace used by Tomcat 10 and later.
+	Jakarta Namespace = "jakarta"
+
+	// Javax is the javax.servlet namespace used by Tomcat 9 and earlier.
+	Javax Namespace = "javax"
+
+	// UnknownNamespace indicates that a web application references neither namespace.
+	UnknownNamespace Namespace = ""
+)
+
+// JakartaVersion is the version of Tomcat selected for web applications that use, or are migrated to, the Jakarta
+// namespace.
+const JakartaVersion = "10.*"
+
+var (
+	jakartaServlet = []byte("jakarta/servlet/")
+	javaxServlet   = []byte("javax/servlet/")
+)
+
+// ServletNamespace returns the Servlet API namespace referenced by web applications.  Each path is either an exploded
+// web application or a WAR file.  The namespace is the one referenced by most of the classes in WEB-INF/classes.  The
+// jars in WEB-INF/lib are only considered if those classes reference neither namespace, and then also by majority, so
+// that a single bundled library cannot switch the namespace of an application.
+func ServletNamespace(paths ...string) (Namespace, error) {
+	s := &scanner{}
+
+	for _, p := range paths {
+		i, err := os.Stat(p)
+		if err != nil {
+			return UnknownNamespace, err
+		}
+
+		if i.IsDir() {
+			err = s.directory(p)
+		} else {
+			err = s.war(p)
+		}
+		if err != nil {
+			return UnknownNamespace, err
+		}
+	}
+
+	if n := s.classes.namespace(); n != UnknownNamespace {
+		return n, nil
+	}
+
+	return s.libraries.namespace(), nil
+}
+
+// JakartaMigration returns whether web applications using the Javax namespace should be migrated to the Jakarta
+// namespace, as configured by $BP_TOMCAT_JAKARTA_MIGRATION.
+func JakartaMigration() (bool, error) {
+	s, ok := os.LookupEnv("BP_TOMCAT_JAKARTA_MIGRATION")
+	if !ok {
+		return false, nil
+	}
+
+	m, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("$BP_TOMCAT_JAKARTA_MIGRATION must be a boolean: %w", err)
+	}
+
+	return m, nil
+}
+
+// references counts the classes that reference each namespace.
+type references struct {
+	jakarta int
+	javax   int
+}
+
+func (r *references) class(content []byte) {
+	if bytes.Contains(content, jakartaServlet) {
+		r.jakarta++
+	}
+
+	if bytes.Contains(content, javaxServlet) {
+		r.javax++
+	}
+}
+
+func (r references) namespace() Namespace {
+	switch {
+	case r.jakarta > r.javax:
+		return Jakarta
+	case r.javax > 0:
+		return Javax
+	default:
+		return UnknownNamespace
+	}
+}
+
+type scanner struct {
+	classes   references
+	libraries references
+}
+
+func (s *scanner) directory(root string) error {
+	classes := filepath.Join(root, "WEB-INF", "classes")
+	if err := filepath.Walk(classes, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !strings.HasSuffix(path, ".class") {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		s.classes.class(b)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	jars, err := filepath.Glob(filepath.Join(root, "WEB-INF", "lib", "*.jar"))
+	if err != nil {
+		return err
+	}
+
+	for _, j := range jars {
+		r, err := zip.OpenReader(j)
+		if err != nil {
+			return err
+		}
+
+		err = s.archive(&r.Reader, func(string) *references { return &s.libraries })
+		_ = r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *scanner) war(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return s.archive(&r.Reader, func(name string) *references {
+		switch {
+		case strings.HasPrefix(name, "WEB-INF/classes/"):
+			return &s.classes
+		case strings.HasPrefix(name, "WEB-INF/lib/"):
+			return &s.libraries
+		default:
+			return nil
+		}
+	})
+}
+
+// archive counts the references of the classes in an archive, and in the jars nested in it, against the references
+// returned for the name of each entry.  Entries for which no references are returned are skipped.
+func (s *scanner) archive(r *zip.Reader, counter func(name string) *references) error {
+	for _, f := range r.File {
+		c := counter(f.Name)
+		if c == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(f.Name, ".class"):
+			b, err := read(f)
+			if err != nil {
+				return err
+			}
+
+			c.class(b)
+		case strings.HasSuffix(f.Name, ".jar"):
+			b, err := read(f)
+			if err != nil {
+				return err
+			}
+
+			j, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				return err
+			}
+
+			if err := s.archive(j, func(string) *references { return c }); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func read(f *zip.File) ([]byte, error) {
+	in, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	return ioutil.ReadAll(in)
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestNamespace(t *testing.T) {
+	spec.Run(t, "Namespace", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var root string
+
+		it.Before(func() {
+			root = test.ScratchDir(t, "namespace")
+		})
+
+		archive := func(entries map[string][]byte) []byte {
+			var b bytes.Buffer
+
+			w := zip.NewWriter(&b)
+			for name, content := range entries {
+				f, err := w.Create(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := f.Write(content); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			return b.Bytes()
+		}
+
+		writeArchive := func(path string, entries map[string][]byte) {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			test.WriteFile(t, path, "%s", archive(entries))
+		}
+
+		it("returns unknown without servlet references", func() {
+			test.WriteFile(t, filepath.Join(root, "WEB-INF", "classes", "Test.class"), "java/lang/Object")
+
+			g.Expect(internal.ServletNamespace(root)).To(gomega.Equal(internal.UnknownNamespace))
+		})
+
+		it("returns javax from WEB-INF/classes", func() {
+			test.WriteFile(t, filepath.Join(root, "WEB-INF", "classes", "test", "Test.class"), "javax/servlet/http/HttpServlet")
+
+			g.Expect(internal.ServletNamespace(root)).To(gomega.Equal(internal.Javax))
+		})
+
+		it("returns namespace of WEB-INF/classes over WEB-INF/lib", func() {
+			test.WriteFile(t, filepath.Join(root, "WEB-INF", "classes", "Test.class"), "javax/servlet/http/HttpServlet")
+			writeArchive(filepath.Join(root, "WEB-INF", "lib", "test.jar"), map[string][]byte{
+				"test/Alpha.class": []byte("jakarta/servlet/http/HttpServlet"),
+				"test/Bravo.class": []byte("jakarta/servlet/http/HttpServlet"),
+			})
+
+			g.Expect(internal.ServletNamespace(root)).To(gomega.Equal(internal.Javax))
+		})
+
+		it("returns majority namespace of WEB-INF/classes", func() {
+			test.WriteFile(t, filepath.Join(root, "WEB-INF", "classes", "Alpha.class"), "jakarta/servlet/http/HttpServlet")
+			test.WriteFile(t, filepath.Join(root, "WEB-INF", "classes", "Bravo.class"), "jakarta/servlet/Filter")
+			test.WriteFile(t, filepath.Join(root, "WEB-INF", "classes", "Charlie.class"), "javax/servlet/Filter")
+
+			g.Expect(internal.ServletNamespace(root)).To(gomega.Equal(internal.Jakarta))
+		})
+
+		it("returns jakarta from WEB-INF/lib without servlet references in WEB-INF/classes", func() {
+			test.WriteFile(t, filepath.Join(root, "WEB-INF", "classes", "Test.class"), "java/lang/Object")
+			writeArchive(filepath.Join(root, "WEB-INF", "lib", "test.jar"), map[string][]byte{
+				"test/Test.class": []byte("jakarta/servlet/http/HttpServlet"),
+			})
+
+			g.Expect(internal.ServletNamespace(root)).To(gomega.Equal(internal.Jakarta))
+		})
+
+		it("returns majority namespace of WEB-INF/lib", func() {
+			writeArchive(filepath.Join(root, "WEB-INF", "lib", "alpha.jar"), map[string][]byte{
+				"alpha/Alpha.class": []byte("javax/servlet/http/HttpServlet"),
+				"alpha/Bravo.class": []byte("javax/servlet/Filter"),
+			})
+			writeArchive(filepath.Join(root, "WEB-INF", "lib", "bravo.jar"), map[string][]byte{
+				"bravo/Alpha.class": []byte("jakarta/servlet/http/HttpServlet"),
+			})
+
+			g.Expect(internal.ServletNamespace(root)).To(gomega.Equal(internal.Javax))
+		})
+
+		it("returns namespace from WAR", func() {
+			war := filepath.Join(root, "test.war")
+			writeArchive(war, map[string][]byte{
+				"WEB-INF/lib/test.jar": archive(map[string][]byte{
+					"test/Test.class": []byte("jakarta/servlet/http/HttpServlet"),
+				}),
+			})
+
+			g.Expect(internal.ServletNamespace(war)).To(gomega.Equal(internal.Jakarta))
+		})
+
+		it("returns namespace of WEB-INF/classes over WEB-INF/lib in WAR", func() {
+			war := filepath.Join(root, "test.war")
+			writeArchive(war, map[string][]byte{
+				"WEB-INF/classes/test/Test.class": []byte("javax/servlet/http/HttpServlet"),
+				"WEB-INF/lib/test.jar": archive(map[string][]byte{
+					"test/Test.class": []byte("jakarta/servlet/http/HttpServlet"),
+				}),
+			})
+
+			g.Expect(internal.ServletNamespace(war)).To(gomega.Equal(internal.Javax))
+		})
+
+		it("ignores classes outside WEB-INF in WAR", func() {
+			war := filepath.Join(root, "test.war")
+			writeArchive(war, map[string][]byte{
+				"Test.class": []byte("jakarta/servlet/http/HttpServlet"),
+			})
+
+			g.Expect(internal.ServletNamespace(war)).To(gomega.Equal(internal.UnknownNamespace))
+		})
+
+		it("fails with invalid $BP_TOMCAT_JAKARTA_MIGRATION", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "test-value")()
+
+			_, err := internal.JakartaMigration()
+			g.Expect(err).To(gomega.HaveOccurred())
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libbuildpack/v2/stack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+)
+
+const (
+	// OverridePrefix is the prefix of the environment variables that override a dependency.
+	OverridePrefix = "BP_TOMCAT_OVERRIDE_"
+
+	// TomcatCompatibility is the key for the buildpack metadata that maps versions of Tomcat to the versions of the
+	// dependencies that are compatible with them.
+	TomcatCompatibility = "tomcat-compatibility"
+)
+
+var overrideSuffixes = []string{"_SHA256", "_URI", "_VERSION"}
+
+// Override replaces every buildpack.toml entry for a dependency with a user supplied artifact.  It is configured with
+// $BP_TOMCAT_OVERRIDE_<ID>_VERSION, $BP_TOMCAT_OVERRIDE_<ID>_URI, and $BP_TOMCAT_OVERRIDE_<ID>_SHA256, where <ID> is
+// the dependency id upper-cased with "_" in place of "-".  Only a dependency in buildpack.toml can be overridden, and the
+// version of an override must satisfy $BP_TOMCAT_VERSION, for Tomcat, or a "tomcat-compatibility" range, for others.
+func Override(deps buildpack.Dependencies, metadata map[string]interface{}, stack stack.Stack) (buildpack.Dependencies, error) {
+	for _, id := range overriddenIDs() {
+		o, err := override(deps, metadata, id, stack)
+		if err != nil {
+			return nil, err
+		}
+
+		var d buildpack.Dependencies
+		for _, dep := range deps {
+			if dep.ID != id {
+				d = append(d, dep)
+			}
+		}
+		deps = append(d, o)
+	}
+
+	return deps, nil
+}
+
+// Overridden returns whether a dependency is overridden by a user supplied artifact.
+func Overridden(id string) bool {
+	_, ok := os.LookupEnv(overrideVariable(id, "_URI"))
+	return ok
+}
+
+func override(deps buildpack.Dependencies, metadata map[string]interface{}, id string, stack stack.Stack) (buildpack.Dependency, error) {
+	v, vOk := os.LookupEnv(overrideVariable(id, "_VERSION"))
+	u, uOk := os.LookupEnv(overrideVariable(id, "_URI"))
+	s, sOk := os.LookupEnv(overrideVariable(id, "_SHA256"))
+
+	if !vOk || !uOk || !sOk {
+		return buildpack.Dependency{}, fmt.Errorf("all of $%s, $%s, and $%s must be set",
+			overrideVariable(id, "_VERSION"), overrideVariable(id, "_URI"), overrideVariable(id, "_SHA256"))
+	}
+
+	version, err := semver.NewVersion(v)
+	if err != nil {
+		return buildpack.Dependency{}, fmt.Errorf("$%s must be a semver version: %w", overrideVariable(id, "_VERSION"), err)
+	}
+
+	if err := compatible(metadata, id, version); err != nil {
+		return buildpack.Dependency{}, err
+	}
+
+	o := buildpack.Dependency{
+		ID:      id,
+		Version: buildpack.Version{Version: version},
+		URI:     u,
+		SHA256:  s,
+		Stacks:  buildpack.Stacks{stack},
+	}
+
+	// Retain the name and licenses of the dependency being overridden
+	for _, d := range deps {
+		if d.ID == id {
+			o.Name, o.Licenses = d.Name, d.Licenses
+			return o, nil
+		}
+	}
+
+	return buildpack.Dependency{}, fmt.Errorf("$%s overrides %s, which is not a dependency in buildpack.toml",
+		overrideVariable(id, "_URI"), id)
+}
+
+// compatible fails if the version of an override does not satisfy $BP_TOMCAT_VERSION, for Tomcat, or any of the
+// "tomcat-compatibility" ranges for the dependency, for others.
+func compatible(metadata map[string]interface{}, id string, version *semver.Version) error {
+	if id == "tomcat" {
+		r, ok := os.LookupEnv("BP_TOMCAT_VERSION")
+		if !ok {
+			return nil
+		}
+
+		c, err := semver.NewConstraint(r)
+		if err != nil {
+			return fmt.Errorf("$BP_TOMCAT_VERSION must be a semver constraint: %w", err)
+		}
+
+		if !c.Check(version) {
+			return fmt.Errorf("$%s %s does not satisfy $BP_TOMCAT_VERSION %s",
+				overrideVariable(id, "_VERSION"), version.Original(), r)
+		}
+
+		return nil
+	}
+
+	entries, _ := metadata[TomcatCompatibility].([]map[string]interface{})
+
+	var ranges []string
+	for _, e := range entries {
+		if e["id"] != id {
+			continue
+		}
+
+		r, ok := e["version"].(string)
+		if !ok {
+			return fmt.Errorf("%s entry for %s does not have a version", TomcatCompatibility, id)
+		}
+
+		c, err := semver.NewConstraint(r)
+		if err != nil {
+			return fmt.Errorf("%s entry for %s has an invalid version: %w", TomcatCompatibility, id, err)
+		}
+
+		if c.Check(version) {
+			return nil
+		}
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("$%s %s is not compatible with Tomcat, which requires %s %s",
+		overrideVariable(id, "_VERSION"), version.Original(), id, strings.Join(ranges, " or "))
+}
+
+func overriddenIDs() []string {
+	ids := make(map[string]bool)
+
+	for _, e := range os.Environ() {
+		k := strings.SplitN(e, "=", 2)[0]
+		if !strings.HasPrefix(k, OverridePrefix) {
+			continue
+		}
+
+		for _, s := range overrideSuffixes {
+			if strings.HasSuffix(k, s) && len(k) > len(OverridePrefix)+len(s) {
+				ids[strings.ToLower(strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(k, OverridePrefix), s), "_", "-"))] = true
+			}
+		}
+	}
+
+	var i []string
+	for id := range ids {
+		i = append(i, id)
+	}
+	sort.Strings(i)
+
+	return i
+}
+
+func overrideVariable(id string, suffix string) string {
+	return OverridePrefix + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + suffix
+}
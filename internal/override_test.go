@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestOverride(t *testing.T) {
+	spec.Run(t, "Override", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		deps := buildpack.Dependencies{
+			{
+				ID:       "tomcat",
+				Name:     "Apache Tomcat",
+				Version:  buildpack.Version{Version: semver.MustParse("9.0.0")},
+				URI:      "https://localhost/tomcat-9.0.0.tar.gz",
+				SHA256:   "test-sha256-1",
+				Licenses: buildpack.Licenses{{Type: "Apache-2.0"}},
+			},
+			{
+				ID:      "tomcat",
+				Name:    "Apache Tomcat",
+				Version: buildpack.Version{Version: semver.MustParse("9.0.1")},
+				URI:     "https://localhost/tomcat-9.0.1.tar.gz",
+				SHA256:  "test-sha256-2",
+			},
+			{
+				ID:      "tomcat-logging-support",
+				Name:    "Apache Tomcat Logging Support",
+				Version: buildpack.Version{Version: semver.MustParse("3.3.0")},
+				URI:     "https://localhost/tomcat-logging-support-3.3.0.jar",
+				SHA256:  "test-sha256-3",
+			},
+		}
+
+		metadata := map[string]interface{}{
+			internal.TomcatCompatibility: []map[string]interface{}{
+				{"id": "tomcat-logging-support", "tomcat": ">= 7.0.0, < 11.0.0", "version": "3.*"},
+			},
+		}
+
+		it("does not override without configuration", func() {
+			g.Expect(internal.Override(deps, metadata, "test-stack")).To(gomega.Equal(deps))
+		})
+
+		it("replaces every entry of an overridden dependency", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_VERSION", "9.0.2+patched")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_URI", "https://example.com/tomcat-patched.tar.gz")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SHA256", "test-sha256")()
+
+			o, err := internal.Override(deps, metadata, "test-stack")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(o).To(gomega.Equal(buildpack.Dependencies{
+				deps[2],
+				{
+					ID:       "tomcat",
+					Name:     "Apache Tomcat",
+					Version:  buildpack.Version{Version: semver.MustParse("9.0.2+patched")},
+					URI:      "https://example.com/tomcat-patched.tar.gz",
+					SHA256:   "test-sha256",
+					Stacks:   buildpack.Stacks{"test-stack"},
+					Licenses: buildpack.Licenses{{Type: "Apache-2.0"}},
+				},
+			}))
+			g.Expect(internal.Overridden("tomcat")).To(gomega.BeTrue())
+			g.Expect(internal.Overridden("tomcat-logging-support")).To(gomega.BeFalse())
+		})
+
+		it("overrides dependency with hyphenated id", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_VERSION", "3.3.1")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_URI", "https://example.com/fork.jar")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_SHA256", "test-sha256")()
+
+			o, err := internal.Override(deps, metadata, "test-stack")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(o).To(gomega.HaveLen(3))
+			g.Expect(o[2].ID).To(gomega.Equal("tomcat-logging-support"))
+			g.Expect(o[2].URI).To(gomega.Equal("https://example.com/fork.jar"))
+		})
+
+		it("fails with dependency not in buildpack.toml", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_NATIVE_VERSION", "1.2.23")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_NATIVE_URI", "https://example.com/tomcat-native.tar.gz")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_NATIVE_SHA256", "test-sha256")()
+
+			_, err := internal.Override(deps, metadata, "test-stack")
+			g.Expect(err).To(gomega.MatchError(
+				"$BP_TOMCAT_OVERRIDE_TOMCAT_NATIVE_URI overrides tomcat-native, which is not a dependency in buildpack.toml"))
+		})
+
+		it("fails with version not satisfying $BP_TOMCAT_VERSION", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "8.*")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_VERSION", "9.0.2+patched")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_URI", "https://example.com/tomcat-patched.tar.gz")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SHA256", "test-sha256")()
+
+			_, err := internal.Override(deps, metadata, "test-stack")
+			g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_OVERRIDE_TOMCAT_VERSION 9.0.2+patched does not satisfy $BP_TOMCAT_VERSION 8.*"))
+		})
+
+		it("fails with version not compatible with Tomcat", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_VERSION", "4.0.0")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_URI", "https://example.com/fork.jar")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_SHA256", "test-sha256")()
+
+			_, err := internal.Override(deps, metadata, "test-stack")
+			g.Expect(err).To(gomega.MatchError("$BP_TOMCAT_OVERRIDE_TOMCAT_LOGGING_SUPPORT_VERSION 4.0.0 is not compatible with Tomcat, " +
+				"which requires tomcat-logging-support 3.*"))
+		})
+
+		it("fails without all of version, URI, and SHA256", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_URI", "https://example.com/tomcat-patched.tar.gz")()
+
+			_, err := internal.Override(deps, metadata, "test-stack")
+			g.Expect(err).To(gomega.MatchError("all of $BP_TOMCAT_OVERRIDE_TOMCAT_VERSION, $BP_TOMCAT_OVERRIDE_TOMCAT_URI, and $BP_TOMCAT_OVERRIDE_TOMCAT_SHA256 must be set"))
+		})
+
+		it("fails with invalid version", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_VERSION", "test-version")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_URI", "https://example.com/tomcat-patched.tar.gz")()
+			defer test.ReplaceEnv(t, "BP_TOMCAT_OVERRIDE_TOMCAT_SHA256", "test-sha256")()
+
+			_, err := internal.Override(deps, metadata, "test-stack")
+			g.Expect(err).To(gomega.MatchError(gomega.HavePrefix("$BP_TOMCAT_OVERRIDE_TOMCAT_VERSION must be a semver version")))
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// SourceDateEpoch is the environment variable that overrides the modification time of normalized files.
+const SourceDateEpoch = "SOURCE_DATE_EPOCH"
+
+// DefaultEpoch is the modification time of normalized files when $SOURCE_DATE_EPOCH is not set.  It matches the time
+// the lifecycle gives the files of a reproducible image.
+var DefaultEpoch = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+// Epoch returns the time that normalized files are given, $SOURCE_DATE_EPOCH seconds since the Unix epoch if it is
+// set, and DefaultEpoch otherwise.
+func Epoch() (time.Time, error) {
+	s, ok := os.LookupEnv(SourceDateEpoch)
+	if !ok || s == "" {
+		return DefaultEpoch, nil
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || i < 0 {
+		return time.Time{}, fmt.Errorf("$%s must be a non-negative number of seconds: %s", SourceDateEpoch, s)
+	}
+
+	return time.Unix(i, 0).UTC(), nil
+}
+
+// Normalize makes the contents of root independent of when and by whom they were written so that contributing the
+// same inputs twice results in identical layers.  Directories and executable files are given mode 0755 and other files
+// 0644, whatever the umask, or 0700 and 0600 if only their owner has access to them.  Every entry, including symlinks
+// which are never followed, is given the time returned by Epoch.
+func Normalize(root string) error {
+	epoch, err := Epoch()
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			if err := os.Chmod(path, mode(info)); err != nil {
+				return err
+			}
+		}
+
+		paths = append(paths, path)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	t := unix.NsecToTimespec(epoch.UnixNano())
+
+	// Children before their parents so that no later change touches a directory that has already been normalized
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, paths[i], []unix.Timespec{t, t}, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return fmt.Errorf("unable to set modification time of %s: %w", paths[i], err)
+		}
+	}
+
+	return nil
+}
+
+func mode(info os.FileInfo) os.FileMode {
+	m := os.FileMode(0644)
+	if info.IsDir() || info.Mode()&0111 != 0 {
+		m = 0755
+	}
+
+	if info.Mode()&0077 == 0 {
+		m &= 0700
+	}
+
+	return m
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestReproducible(t *testing.T) {
+	spec.Run(t, "Reproducible", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var root string
+
+		it.Before(func() {
+			root = test.ScratchDir(t, "reproducible")
+		})
+
+		when("Epoch", func() {
+
+			it("defaults to 1980", func() {
+				defer test.ReplaceEnv(t, "SOURCE_DATE_EPOCH", "")()
+
+				g.Expect(internal.Epoch()).To(gomega.Equal(internal.DefaultEpoch))
+			})
+
+			it("uses $SOURCE_DATE_EPOCH", func() {
+				defer test.ReplaceEnv(t, "SOURCE_DATE_EPOCH", "1577836800")()
+
+				g.Expect(internal.Epoch()).To(gomega.Equal(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)))
+			})
+
+			it("fails with invalid $SOURCE_DATE_EPOCH", func() {
+				defer test.ReplaceEnv(t, "SOURCE_DATE_EPOCH", "yesterday")()
+
+				_, err := internal.Epoch()
+				g.Expect(err).To(gomega.MatchError("$SOURCE_DATE_EPOCH must be a non-negative number of seconds: yesterday"))
+			})
+		})
+
+		when("Normalize", func() {
+
+			it.Before(func() {
+				test.WriteFile(t, filepath.Join(root, "dir", "file"), "test-content")
+				g.Expect(os.Chmod(filepath.Join(root, "dir", "file"), 0666)).To(gomega.Succeed())
+				g.Expect(os.Chmod(filepath.Join(root, "dir"), 0777)).To(gomega.Succeed())
+				g.Expect(os.MkdirAll(filepath.Join(root, "private"), 0700)).To(gomega.Succeed())
+				test.WriteFile(t, filepath.Join(root, "private", "executable"), "test-content")
+				g.Expect(os.Chmod(filepath.Join(root, "private", "executable"), 0700)).To(gomega.Succeed())
+				test.WriteFile(t, filepath.Join(root, "private", "file"), "test-content")
+				g.Expect(os.Chmod(filepath.Join(root, "private", "file"), 0600)).To(gomega.Succeed())
+				g.Expect(os.Symlink(filepath.Join(root, "dir", "file"), filepath.Join(root, "link"))).To(gomega.Succeed())
+				g.Expect(os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "dangling"))).To(gomega.Succeed())
+			})
+
+			it("sets modification times without following symlinks", func() {
+				defer test.ReplaceEnv(t, "SOURCE_DATE_EPOCH", "1577836800")()
+
+				g.Expect(internal.Normalize(root)).To(gomega.Succeed())
+
+				for _, f := range []string{"", "dir", "dir/file", "private", "link", "dangling"} {
+					info, err := os.Lstat(filepath.Join(root, f))
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+					g.Expect(info.ModTime().UTC()).To(gomega.Equal(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)), f)
+				}
+
+				g.Expect(os.Readlink(filepath.Join(root, "link"))).To(gomega.Equal(filepath.Join(root, "dir", "file")))
+			})
+
+			it("sets canonical permissions", func() {
+				g.Expect(internal.Normalize(root)).To(gomega.Succeed())
+
+				for f, m := range map[string]os.FileMode{
+					"dir":                0755,
+					"dir/file":           0644,
+					"private":            0700,
+					"private/executable": 0700,
+					"private/file":       0600,
+				} {
+					info, err := os.Stat(filepath.Join(root, f))
+					g.Expect(err).NotTo(gomega.HaveOccurred())
+					g.Expect(info.Mode().Perm()).To(gomega.Equal(m), f)
+				}
+			})
+
+			it("fails with invalid $SOURCE_DATE_EPOCH", func() {
+				defer test.ReplaceEnv(t, "SOURCE_DATE_EPOCH", "-1")()
+
+				g.Expect(internal.Normalize(root)).NotTo(gomega.Succeed())
+			})
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+const publicKeyBlock = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+// VerifySignature verifies an armored, detached signature of an artifact against the public keys in a KEYS file.  A
+// KEYS file, as published by Apache projects, may contain any number of armored public key blocks separated by text.
+func VerifySignature(artifact string, signature string, keys string) error {
+	k, err := readKeys(keys)
+	if err != nil {
+		return err
+	}
+
+	a, err := os.Open(artifact)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	s, err := os.Open(signature)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(k, a, s, nil); err != nil {
+		return fmt.Errorf("unable to verify signature of %s: %w", artifact, err)
+	}
+
+	return nil
+}
+
+func readKeys(file string) (openpgp.EntityList, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys openpgp.EntityList
+
+	blocks := strings.Split(string(b), publicKeyBlock)
+	for _, block := range blocks[1:] {
+		el, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKeyBlock + block))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read public key from %s: %w", file, err)
+		}
+
+		keys = append(keys, el...)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys found in %s", file)
+	}
+
+	return keys, nil
+}
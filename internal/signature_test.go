@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package internal_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/internal"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestSignature(t *testing.T) {
+	spec.Run(t, "Signature", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			artifact  string
+			keys      string
+			root      string
+			signature string
+			signer    *openpgp.Entity
+		)
+
+		entity := func() *openpgp.Entity {
+			e, err := openpgp.NewEntity("test-name", "", "test@example.com", &packet.Config{RSABits: 1024})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return e
+		}
+
+		publicKey := func(e *openpgp.Entity) string {
+			var b bytes.Buffer
+
+			w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := e.Serialize(w); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			return b.String()
+		}
+
+		sign := func(e *openpgp.Entity) {
+			a, err := os.Open(artifact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Close()
+
+			var b bytes.Buffer
+			if err := openpgp.ArmoredDetachSign(&b, e, a, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			test.WriteFile(t, signature, "%s", b.String())
+		}
+
+		it.Before(func() {
+			root = test.ScratchDir(t, "signature")
+			artifact = filepath.Join(root, "test-artifact.tar.gz")
+			keys = filepath.Join(root, "KEYS")
+			signature = filepath.Join(root, "test-artifact.tar.gz.asc")
+
+			test.WriteFile(t, artifact, "test-content")
+
+			signer = entity()
+			test.WriteFile(t, keys, "This file contains the PGP keys of various developers.\n\npub   rsa1024\n%s\npub   rsa1024\n%s",
+				publicKey(entity()), publicKey(signer))
+		})
+
+		it("verifies signature", func() {
+			sign(signer)
+
+			g.Expect(internal.VerifySignature(artifact, signature, keys)).To(gomega.Succeed())
+		})
+
+		it("fails with modified artifact", func() {
+			sign(signer)
+			test.WriteFile(t, artifact, "modified-content")
+
+			g.Expect(internal.VerifySignature(artifact, signature, keys)).
+				To(gomega.MatchError(gomega.HavePrefix(fmt.Sprintf("unable to verify signature of %s", artifact))))
+		})
+
+		it("fails with unknown signer", func() {
+			sign(entity())
+
+			g.Expect(internal.VerifySignature(artifact, signature, keys)).
+				To(gomega.MatchError(gomega.HavePrefix(fmt.Sprintf("unable to verify signature of %s", artifact))))
+		})
+
+		it("fails without keys", func() {
+			sign(signer)
+			test.WriteFile(t, keys, "This file contains no keys.\n")
+
+			g.Expect(internal.VerifySignature(artifact, signature, keys)).
+				To(gomega.MatchError(fmt.Sprintf("no public keys found in %s", keys)))
+		})
+	}, spec.Report(report.Terminal{}))
+}
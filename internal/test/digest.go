@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Digest returns the mode, modification time, and contents or symlink target of every entry under root, keyed by path
+// relative to root, so that two contributions of a layer can be compared.
+func Digest(t *testing.T, root string) map[string]string {
+	t.Helper()
+
+	d := make(map[string]string)
+
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		c := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if c, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if info.Mode().IsRegular() {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			c = string(b)
+		}
+
+		d[strings.TrimPrefix(path, root)] = fmt.Sprintf("%s %s %s", info.Mode(), info.ModTime().UTC(), c)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+)
+
+var (
+	signer     *openpgp.Entity
+	signerErr  error
+	signerOnce sync.Once
+)
+
+// SignDependencies writes a KEYS file to the buildpack root and a detached signature of every cached dependency,
+// adding its location to the dependency's entry in buildpack.toml.
+func SignDependencies(t *testing.T, f *test.BuildFactory) {
+	t.Helper()
+
+	signerOnce.Do(func() {
+		signer, signerErr = openpgp.NewEntity("test-name", "", "test@example.com", &packet.Config{RSABits: 1024})
+	})
+	if signerErr != nil {
+		t.Fatal(signerErr)
+	}
+
+	var keys bytes.Buffer
+	w, err := armor.Encode(&keys, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := helper.WriteFile(filepath.Join(f.Build.Buildpack.Root, "KEYS"), 0644, "%s", keys.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	deps, _ := f.Build.Buildpack.Metadata[buildpack.DependenciesMetadata].([]map[string]interface{})
+	for _, d := range deps {
+		s := filepath.Join("signatures", fmt.Sprintf("%s-%s.asc", d["id"], d["version"]))
+		sign(t, filepath.Join(f.Build.Layers.Layer(d["sha256"].(string)).Root, d["name"].(string)),
+			filepath.Join(f.Build.Buildpack.Root, s))
+		d["signature"] = s
+	}
+}
+
+func sign(t *testing.T, artifact string, signature string) {
+	t.Helper()
+
+	a, err := os.Open(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, signer, a, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := helper.WriteFile(signature, 0644, "%s", b.String()); err != nil {
+		t.Fatal(err)
+	}
+}
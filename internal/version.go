@@ -17,25 +17,123 @@
 package internal
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/Masterminds/semver"
 	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
 	"github.com/cloudfoundry/libcfbuildpack/v2/buildpackplan"
 )
 
+// VersionMetadata is the build plan metadata key of the version an entry requires.  Buildpack API 0.3 and later move a
+// version out of the entry itself and into its metadata.
+const VersionMetadata = "version"
+
+// PlanVersion returns the version a build plan entry requires, from either the entry or its metadata.
+func PlanVersion(plan buildpackplan.Plan) string {
+	if plan.Version != "" {
+		return plan.Version
+	}
+
+	v, _ := plan.Metadata[VersionMetadata].(string)
+	return v
+}
+
 // Version returns the selected version of Tomcat using the following precedence:
 //
 // 1. $BP_TOMCAT_VERSION
-// 2. Build Plan Version
-// 3. Buildpack Metadata "default_versions"
-func Version(id string, plan buildpackplan.Plan, buildpack buildpack.Buildpack) (string, error) {
+// 2. Intersection of Build Plan Versions
+// 3. JakartaVersion if the web applications use, or are migrated to, the Jakarta namespace and deps has that version
+// 4. Buildpack Metadata "default_versions"
+func Version(id string, plans []buildpackplan.Plan, buildpack buildpack.Buildpack, deps buildpack.Dependencies, namespace Namespace) (string, error) {
 	if version, ok := os.LookupEnv("BP_TOMCAT_VERSION"); ok {
 		return version, nil
 	}
 
-	if plan.Version != "" {
-		return plan.Version, nil
+	var versions []string
+	for _, p := range plans {
+		if v := PlanVersion(p); v != "" {
+			versions = append(versions, v)
+		}
+	}
+
+	if len(versions) > 0 {
+		return Intersect(versions...), nil
+	}
+
+	if !hasJakartaVersion(id, deps) {
+		return buildpack.DefaultVersion(id)
+	}
+
+	if namespace == Jakarta {
+		return JakartaVersion, nil
+	}
+
+	if namespace == Javax {
+		if m, err := JakartaMigration(); err != nil {
+			return "", err
+		} else if m {
+			return JakartaVersion, nil
+		}
 	}
 
 	return buildpack.DefaultVersion(id)
 }
+
+// hasJakartaVersion returns whether deps contain a version of a dependency that satisfies JakartaVersion, so that it is
+// only selected once buildpack.toml, or an override, provides it.
+func hasJakartaVersion(id string, deps buildpack.Dependencies) bool {
+	c, err := semver.NewConstraint(JakartaVersion)
+	if err != nil {
+		return false
+	}
+
+	for _, d := range deps {
+		if d.ID == id && c.Check(d.Version.Version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Intersect returns a version constraint satisfied only by versions that satisfy every one of constraints.  Constraints
+// are ANDed with "," and, as "," binds more tightly than "||", alternatives are distributed across the other
+// constraints.  For example ">= 9.0.30" and "8.* || 9.*" intersect to ">= 9.0.30, 8.* || >= 9.0.30, 9.*".
+func Intersect(constraints ...string) string {
+	intersection := []string{""}
+
+	for _, c := range constraints {
+		var next []string
+
+		for _, i := range intersection {
+			for _, a := range strings.Split(c, "||") {
+				a = strings.TrimSpace(a)
+				if i == "" {
+					next = append(next, a)
+				} else {
+					next = append(next, fmt.Sprintf("%s, %s", i, a))
+				}
+			}
+		}
+
+		intersection = next
+	}
+
+	return strings.Join(intersection, " || ")
+}
+
+// Requirements describes the versions build plan entries require, so that unsatisfiable requirements can be reported.
+// The build plan does not identify the buildpack that required an entry, so only the versions are described.
+func Requirements(plans []buildpackplan.Plan) string {
+	var r []string
+
+	for _, p := range plans {
+		if v := PlanVersion(p); v != "" {
+			r = append(r, fmt.Sprintf("%q", v))
+		}
+	}
+
+	return strings.Join(r, ", ")
+}
@@ -19,6 +19,7 @@ package internal_test
 import (
 	"testing"
 
+	"github.com/Masterminds/semver"
 	bp "github.com/buildpacks/libbuildpack/v2/buildpack"
 	"github.com/cloudfoundry/libcfbuildpack/v2/buildpack"
 	"github.com/cloudfoundry/libcfbuildpack/v2/buildpackplan"
@@ -38,30 +39,90 @@ func TestVersion(t *testing.T) {
 		it("uses $BP_TOMCAT_VERSION if set", func() {
 			defer test.ReplaceEnv(t, "BP_TOMCAT_VERSION", "test-version")()
 			buildpack := buildpack.NewBuildpack(bp.Buildpack{}, logger.Logger{})
-			plan := buildpackplan.Plan{}
+			plans := []buildpackplan.Plan{{}}
 
-			g.Expect(internal.Version("test-id", plan, buildpack)).To(gomega.Equal("test-version"))
+			g.Expect(internal.Version("test-id", plans, buildpack, nil, internal.UnknownNamespace)).To(gomega.Equal("test-version"))
 		})
 
 		it("uses build plan version if set", func() {
 			buildpack := buildpack.NewBuildpack(bp.Buildpack{}, logger.Logger{})
-			plan := buildpackplan.Plan{Version: "test-version"}
+			plans := []buildpackplan.Plan{{Version: "test-version"}}
 
-			g.Expect(internal.Version("test-id", plan, buildpack)).To(gomega.Equal("test-version"))
+			g.Expect(internal.Version("test-id", plans, buildpack, nil, internal.UnknownNamespace)).To(gomega.Equal("test-version"))
+		})
+
+		it("intersects build plan versions", func() {
+			buildpack := buildpack.NewBuildpack(bp.Buildpack{}, logger.Logger{})
+			plans := []buildpackplan.Plan{{Version: ">= 9.0.30"}, {}, {Version: "9.*"}}
+
+			g.Expect(internal.Version("test-id", plans, buildpack, nil, internal.UnknownNamespace)).To(gomega.Equal(">= 9.0.30, 9.*"))
+		})
+
+		it("distributes alternatives when intersecting", func() {
+			g.Expect(internal.Intersect(">= 9.0.30", "8.* || 9.*")).To(gomega.Equal(">= 9.0.30, 8.* || >= 9.0.30, 9.*"))
+		})
+
+		it("describes build plan requirements", func() {
+			plans := []buildpackplan.Plan{
+				{Version: ">= 9.0.30, < 10.0.0"},
+				{},
+				{Metadata: buildpackplan.Metadata{"version": "8.*"}},
+			}
+
+			g.Expect(internal.Requirements(plans)).To(gomega.Equal(`">= 9.0.30, < 10.0.0", "8.*"`))
+		})
+
+		jakarta := buildpack.Dependencies{{ID: "test-id", Version: buildpack.Version{Version: semver.MustParse("10.0.0")}}}
+
+		it("uses Jakarta version for Jakarta namespace", func() {
+			buildpack := buildpack.NewBuildpack(bp.Buildpack{Metadata: buildpack.Metadata{"default-versions": map[string]interface{}{"test-id": "test-version"}}}, logger.Logger{})
+			plans := []buildpackplan.Plan{{}}
+
+			g.Expect(internal.Version("test-id", plans, buildpack, jakarta, internal.Jakarta)).To(gomega.Equal("10.*"))
+		})
+
+		it("uses buildpack default version for Jakarta namespace without Jakarta version", func() {
+			deps := buildpack.Dependencies{{ID: "test-id", Version: buildpack.Version{Version: semver.MustParse("9.0.0")}}}
+			buildpack := buildpack.NewBuildpack(bp.Buildpack{Metadata: buildpack.Metadata{"default-versions": map[string]interface{}{"test-id": "test-version"}}}, logger.Logger{})
+			plans := []buildpackplan.Plan{{}}
+
+			g.Expect(internal.Version("test-id", plans, buildpack, deps, internal.Jakarta)).To(gomega.Equal("test-version"))
+		})
+
+		it("uses buildpack default version for Javax namespace", func() {
+			buildpack := buildpack.NewBuildpack(bp.Buildpack{Metadata: buildpack.Metadata{"default-versions": map[string]interface{}{"test-id": "test-version"}}}, logger.Logger{})
+			plans := []buildpackplan.Plan{{}}
+
+			g.Expect(internal.Version("test-id", plans, buildpack, nil, internal.Javax)).To(gomega.Equal("test-version"))
+		})
+
+		it("uses Jakarta version for Javax namespace with $BP_TOMCAT_JAKARTA_MIGRATION", func() {
+			defer test.ReplaceEnv(t, "BP_TOMCAT_JAKARTA_MIGRATION", "true")()
+			buildpack := buildpack.NewBuildpack(bp.Buildpack{Metadata: buildpack.Metadata{"default-versions": map[string]interface{}{"test-id": "test-version"}}}, logger.Logger{})
+			plans := []buildpackplan.Plan{{}}
+
+			g.Expect(internal.Version("test-id", plans, buildpack, jakarta, internal.Javax)).To(gomega.Equal("10.*"))
+		})
+
+		it("uses build plan version over Jakarta version", func() {
+			buildpack := buildpack.NewBuildpack(bp.Buildpack{}, logger.Logger{})
+			plans := []buildpackplan.Plan{{Version: "test-version"}}
+
+			g.Expect(internal.Version("test-id", plans, buildpack, nil, internal.Jakarta)).To(gomega.Equal("test-version"))
 		})
 
 		it("uses buildpack default version if set", func() {
 			buildpack := buildpack.NewBuildpack(bp.Buildpack{Metadata: buildpack.Metadata{"default-versions": map[string]interface{}{"test-id": "test-version"}}}, logger.Logger{})
-			plan := buildpackplan.Plan{}
+			plans := []buildpackplan.Plan{{}}
 
-			g.Expect(internal.Version("test-id", plan, buildpack)).To(gomega.Equal("test-version"))
+			g.Expect(internal.Version("test-id", plans, buildpack, nil, internal.UnknownNamespace)).To(gomega.Equal("test-version"))
 		})
 
 		it("return error if none set", func() {
 			buildpack := buildpack.NewBuildpack(bp.Buildpack{Metadata: buildpack.Metadata{"default-versions": map[string]interface{}{"test-id-2": "test-version"}}}, logger.Logger{})
-			plan := buildpackplan.Plan{}
+			plans := []buildpackplan.Plan{{}}
 
-			_, err := internal.Version("test-id", plan, buildpack)
+			_, err := internal.Version("test-id", plans, buildpack, nil, internal.UnknownNamespace)
 			g.Expect(err).To(gomega.MatchError("test-id does not map to a string in default-versions map"))
 		})
 	}, spec.Report(report.Terminal{}))
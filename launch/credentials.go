@@ -0,0 +1,42 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+)
+
+func credential(credentials helper.Credentials, key string) (string, error) {
+	v, ok := credentials[key].(string)
+	if !ok {
+		return "", fmt.Errorf("credential %s is not a string", key)
+	}
+
+	return v, nil
+}
+
+func writeCredential(credentials helper.Credentials, key string, file string, perm os.FileMode) error {
+	v, err := credential(credentials, key)
+	if err != nil {
+		return err
+	}
+
+	return helper.WriteFile(file, perm, "%s", v)
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/buildpacks/libbuildpack/v2/logger"
+	"github.com/buildpacks/libbuildpack/v2/platform"
+	"github.com/buildpacks/libbuildpack/v2/services"
+)
+
+// Database is a kind of database that a DataSource can be generated for.
+type Database struct {
+	// Filter is the value that a service's binding name, instance name, label, or tags must contain.
+	Filter string
+
+	// DriverClassName is the name of the JDBC driver class for the database.
+	DriverClassName string
+
+	// URLPrefix is the prefix of a JDBC URL for the database, to which host, port, and path are appended.
+	URLPrefix string
+}
+
+// Databases are the kinds of database that DataSources can be generated for.
+var Databases = []Database{
+	{"mysql", "org.mariadb.jdbc.Driver", "jdbc:mysql://"},
+	{"oracle", "oracle.jdbc.OracleDriver", "jdbc:oracle:thin:@//"},
+	{"postgres", "org.postgresql.Driver", "jdbc:postgresql://"},
+}
+
+// DatabaseFor returns the kind of database a service is, and true if it is one of Databases.
+func DatabaseFor(service services.Service) (Database, bool) {
+	for _, d := range Databases {
+		if matches(service, d.Filter) {
+			return d, true
+		}
+	}
+
+	return Database{}, false
+}
+
+// DataSourceName returns the JNDI name of the DataSource generated for a service.
+func DataSourceName(service services.Service) string {
+	n := service.BindingName
+	if n == "" {
+		n = service.InstanceName
+	}
+
+	return fmt.Sprintf("jdbc/%s", n)
+}
+
+// DataSourceProperty returns the name of the property that a connection value of a DataSource is read from.
+func DataSourceProperty(dataSource string, value string) string {
+	return fmt.Sprintf("tomcat.datasource.%s.%s", dataSource, value)
+}
+
+// Pool properties are the names of the properties that the pool configuration of every DataSource is read from.
+const (
+	MaxIdleProperty       = "tomcat.datasource.max-idle"
+	MaxTotalProperty      = "tomcat.datasource.max-total"
+	MaxWaitMillisProperty = "tomcat.datasource.max-wait-millis"
+)
+
+// DataSources writes the connection of each bound database service, and the pool configuration, to a
+// catalina.properties file.  The DataSource resources generated at build time read their values from those properties
+// so that no credentials are written into the image.
+func DataSources(file string) error {
+	s, err := services.DefaultServices(platform.Platform{}, logger.Logger{})
+	if err != nil {
+		return err
+	}
+
+	p, err := pool()
+	if err != nil {
+		return err
+	}
+
+	for _, service := range s {
+		d, ok := DatabaseFor(service)
+		if !ok {
+			continue
+		}
+
+		u, username, password, err := connection(service, d)
+		if err != nil {
+			return err
+		}
+
+		n := DataSourceName(service)
+		p[DataSourceProperty(n, "url")] = u
+		p[DataSourceProperty(n, "username")] = username
+		p[DataSourceProperty(n, "password")] = password
+	}
+
+	return WriteProperties(file, "datasources", p)
+}
+
+func pool() (map[string]string, error) {
+	p := make(map[string]string)
+
+	for _, c := range []struct {
+		env          string
+		property     string
+		defaultValue string
+	}{
+		{"BPL_TOMCAT_DATASOURCE_MAX_IDLE", MaxIdleProperty, "8"},
+		{"BPL_TOMCAT_DATASOURCE_MAX_TOTAL", MaxTotalProperty, "8"},
+		{"BPL_TOMCAT_DATASOURCE_MAX_WAIT_MILLIS", MaxWaitMillisProperty, "30000"},
+	} {
+		v := getenv(c.env, c.defaultValue)
+		if _, err := strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("$%s must be an integer: %w", c.env, err)
+		}
+		p[c.property] = v
+	}
+
+	return p, nil
+}
+
+func connection(service services.Service, database Database) (string, string, string, error) {
+	username, _ := service.Credentials["username"].(string)
+	password, _ := service.Credentials["password"].(string)
+
+	if j, ok := service.Credentials["jdbcUrl"].(string); ok {
+		return j, username, password, nil
+	}
+
+	s, ok := service.Credentials["uri"].(string)
+	if !ok {
+		return "", "", "", fmt.Errorf("service %s has neither a jdbcUrl nor a uri credential", service.BindingName)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", "", fmt.Errorf("unable to parse uri credential of service %s: %w", service.BindingName, err)
+	}
+
+	if u.User != nil {
+		username = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			password = p
+		}
+	}
+
+	return fmt.Sprintf("%s%s%s", database.URLPrefix, u.Host, u.EscapedPath()), username, password, nil
+}
+
+func matches(service services.Service, filter string) bool {
+	candidates := append([]string{service.BindingName, service.InstanceName, service.Label}, service.Tags...)
+
+	for _, c := range candidates {
+		if strings.Contains(strings.ToLower(c), filter) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ExecDMarker is the environment variable set by every exec.d executable, so that the profile.d scripts contributed as
+// a fallback for platforms that do not run exec.d executables are skipped.
+const ExecDMarker = "BPI_TOMCAT_EXEC_D"
+
+// AccessLogging returns the environment that activates access logging unless $BPL_TOMCAT_ACCESS_LOGGING is unset or
+// "n".
+func AccessLogging() map[string]string {
+	if e := os.Getenv("BPL_TOMCAT_ACCESS_LOGGING"); e == "" || e == "n" {
+		return nil
+	}
+
+	fmt.Println("Tomcat Access Logging enabled")
+	return map[string]string{"JAVA_OPTS": javaOpts("-Daccess.logging.enabled=true")}
+}
+
+// HTTPS returns the environment that configures the port the HTTPS connector listens on from $BPL_TOMCAT_HTTPS_PORT,
+// and the directory it reads the certificate and private key from.
+func HTTPS(directory string) map[string]string {
+	return map[string]string{"JAVA_OPTS": javaOpts(fmt.Sprintf("-Dport.https=%s -D%s=%s",
+		getenv("BPL_TOMCAT_HTTPS_PORT", "8443"), TLSDirectoryProperty, directory))}
+}
+
+// Port returns the environment that configures the port the HTTP connector listens on from $PORT.
+func Port() map[string]string {
+	return map[string]string{"JAVA_OPTS": javaOpts(fmt.Sprintf("-Dport.http=%s", getenv("PORT", "8080")))}
+}
+
+// WriteEnvironment writes environment variables, and the ExecDMarker, in the TOML format that exec.d executables
+// write to file descriptor 3.
+func WriteEnvironment(w io.Writer, env map[string]string) error {
+	e := map[string]string{ExecDMarker: "true"}
+	for k, v := range env {
+		e[k] = v
+	}
+
+	return toml.NewEncoder(w).Encode(e)
+}
+
+func javaOpts(options string) string {
+	if j := os.Getenv("JAVA_OPTS"); j != "" {
+		return fmt.Sprintf("%s %s", j, options)
+	}
+
+	return options
+}
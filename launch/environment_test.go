@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestEnvironment(t *testing.T) {
+	spec.Run(t, "Environment", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		when("access logging", func() {
+
+			it("does not activate access logging by default", func() {
+				defer test.ReplaceEnv(t, "BPL_TOMCAT_ACCESS_LOGGING", "")()
+
+				g.Expect(launch.AccessLogging()).To(gomega.BeEmpty())
+			})
+
+			it("does not activate access logging with n", func() {
+				defer test.ReplaceEnv(t, "BPL_TOMCAT_ACCESS_LOGGING", "n")()
+
+				g.Expect(launch.AccessLogging()).To(gomega.BeEmpty())
+			})
+
+			it("activates access logging", func() {
+				defer test.ReplaceEnv(t, "BPL_TOMCAT_ACCESS_LOGGING", "y")()
+				defer test.ReplaceEnv(t, "JAVA_OPTS", "-Dtest.key=test-value")()
+
+				g.Expect(launch.AccessLogging()).To(gomega.Equal(map[string]string{
+					"JAVA_OPTS": "-Dtest.key=test-value -Daccess.logging.enabled=true",
+				}))
+			})
+		})
+
+		when("port", func() {
+
+			it("defaults port", func() {
+				defer test.ReplaceEnv(t, "JAVA_OPTS", "")()
+				defer test.ReplaceEnv(t, "PORT", "")()
+
+				g.Expect(launch.Port()).To(gomega.Equal(map[string]string{
+					"JAVA_OPTS": "-Dport.http=8080",
+				}))
+			})
+
+			it("configures port", func() {
+				defer test.ReplaceEnv(t, "JAVA_OPTS", "")()
+				defer test.ReplaceEnv(t, "PORT", "9090")()
+
+				g.Expect(launch.Port()).To(gomega.Equal(map[string]string{
+					"JAVA_OPTS": "-Dport.http=9090",
+				}))
+			})
+
+			it("defaults HTTPS port", func() {
+				defer test.ReplaceEnv(t, "BPL_TOMCAT_HTTPS_PORT", "")()
+				defer test.ReplaceEnv(t, "JAVA_OPTS", "")()
+
+				g.Expect(launch.HTTPS("/tmp/tomcat/tls")).To(gomega.Equal(map[string]string{
+					"JAVA_OPTS": "-Dport.https=8443 -Dtomcat.tls.directory=/tmp/tomcat/tls",
+				}))
+			})
+
+			it("configures HTTPS port", func() {
+				defer test.ReplaceEnv(t, "BPL_TOMCAT_HTTPS_PORT", "9443")()
+				defer test.ReplaceEnv(t, "JAVA_OPTS", "")()
+
+				g.Expect(launch.HTTPS("/tmp/tomcat/tls")).To(gomega.Equal(map[string]string{
+					"JAVA_OPTS": "-Dport.https=9443 -Dtomcat.tls.directory=/tmp/tomcat/tls",
+				}))
+			})
+		})
+
+		it("writes environment with marker", func() {
+			b := &bytes.Buffer{}
+
+			g.Expect(launch.WriteEnvironment(b, map[string]string{"TEST_KEY": `test "value"`})).To(gomega.Succeed())
+			g.Expect(b.String()).To(gomega.Equal(`BPI_TOMCAT_EXEC_D = "true"
+TEST_KEY = "test \"value\""
+`))
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,346 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+)
+
+// JDKJavaOptions are the options that "catalina.sh" adds to $JDK_JAVA_OPTIONS, which Java 9 and later read, so that
+// Tomcat can clear references retained by the JRE.
+const JDKJavaOptions = "--add-opens=java.base/java.lang=ALL-UNNAMED " +
+	"--add-opens=java.base/java.io=ALL-UNNAMED " +
+	"--add-opens=java.rmi/sun.rmi.transport=ALL-UNNAMED"
+
+// JavaOptions are the options that "catalina.sh" adds after $JAVA_OPTS.
+var JavaOptions = []string{
+	"-Djdk.tls.ephemeralDHKeySize=2048",
+	"-Djava.protocol.handler.pkgs=org.apache.catalina.webresources",
+}
+
+var (
+	classpath   = regexp.MustCompile(`^\s*(?:export\s+)?CLASSPATH=(.*)$`)
+	javaVersion = regexp.MustCompile(`(?m)^JAVA_VERSION="?([^"\s]+)"?\s*$`)
+)
+
+// Launcher is the java command line that starts Tomcat in the foreground, equivalent to "catalina.sh run".
+type Launcher struct {
+	// Java is the location of the java executable.
+	Java string
+
+	// Args are the arguments passed to java.
+	Args []string
+
+	// Env is the environment java is executed with.
+	Env []string
+}
+
+// NewLauncher creates a new Launcher instance.  CATALINA_HOME defaults to home and CATALINA_BASE to CATALINA_HOME.
+// The classpath is the CLASSPATH set in setenv.sh followed by bootstrap.jar and tomcat-juli.jar.  $JAVA_OPTS,
+// $CATALINA_OPTS, and options are split into arguments as a shell would, after references to environment variables in
+// options, of the forms $NAME, ${NAME}, and ${NAME:-default}, are expanded.  JDKJavaOptions are added to
+// $JDK_JAVA_OPTIONS only if the release file of the JRE names Java 9 or later.
+func NewLauncher(home string, options ...string) (Launcher, error) {
+	catalinaHome := getenv("CATALINA_HOME", home)
+	catalinaBase := getenv("CATALINA_BASE", catalinaHome)
+	tmp := getenv("CATALINA_TMPDIR", filepath.Join(catalinaBase, "temp"))
+
+	java, err := javaExecutable()
+	if err != nil {
+		return Launcher{}, err
+	}
+
+	cp, err := setenvClasspath(catalinaHome, catalinaBase)
+	if err != nil {
+		return Launcher{}, err
+	}
+
+	cp = append(cp, filepath.Join(catalinaHome, "bin", "bootstrap.jar"))
+
+	juli := filepath.Join(catalinaBase, "bin", "tomcat-juli.jar")
+	if ok, err := helper.FileExists(juli); err != nil {
+		return Launcher{}, err
+	} else if !ok {
+		juli = filepath.Join(catalinaHome, "bin", "tomcat-juli.jar")
+	}
+	cp = append(cp, juli)
+
+	var args []string
+
+	logging := filepath.Join(catalinaBase, "conf", "logging.properties")
+	if ok, err := helper.FileExists(logging); err != nil {
+		return Launcher{}, err
+	} else if ok {
+		args = append(args,
+			fmt.Sprintf("-Djava.util.logging.config.file=%s", logging),
+			"-Djava.util.logging.manager=org.apache.juli.ClassLoaderLogManager")
+	}
+
+	f, err := fields(os.Getenv("JAVA_OPTS"))
+	if err != nil {
+		return Launcher{}, fmt.Errorf("unable to parse $JAVA_OPTS: %w", err)
+	}
+	args = append(args, f...)
+	args = append(args, JavaOptions...)
+
+	if f, err = fields(os.Getenv("CATALINA_OPTS")); err != nil {
+		return Launcher{}, fmt.Errorf("unable to parse $CATALINA_OPTS: %w", err)
+	}
+	args = append(args, f...)
+
+	for _, o := range options {
+		if f, err = fields(expand(o)); err != nil {
+			return Launcher{}, fmt.Errorf("unable to parse %s: %w", o, err)
+		}
+		args = append(args, f...)
+	}
+
+	args = append(args,
+		"-classpath", strings.Join(cp, string(os.PathListSeparator)),
+		fmt.Sprintf("-Dcatalina.base=%s", catalinaBase),
+		fmt.Sprintf("-Dcatalina.home=%s", catalinaHome),
+		fmt.Sprintf("-Djava.io.tmpdir=%s", tmp),
+		"org.apache.catalina.startup.Bootstrap",
+		"start",
+	)
+
+	overrides := map[string]string{
+		"CATALINA_BASE": catalinaBase,
+		"CATALINA_HOME": catalinaHome,
+	}
+
+	resolved := java
+	if j, err := filepath.EvalSymlinks(java); err == nil {
+		resolved = j
+	}
+
+	if v, err := JavaVersion(filepath.Dir(filepath.Dir(resolved))); err != nil {
+		return Launcher{}, err
+	} else if v >= 9 {
+		jdk := JDKJavaOptions
+		if s, ok := os.LookupEnv("JDK_JAVA_OPTIONS"); ok && s != "" {
+			jdk = fmt.Sprintf("%s %s", s, jdk)
+		}
+		overrides["JDK_JAVA_OPTIONS"] = jdk
+	}
+
+	env := environment(overrides)
+
+	return Launcher{java, args, env}, nil
+}
+
+// Command returns the complete command line, including the java executable.
+func (l Launcher) Command() []string {
+	return append([]string{l.Java}, l.Args...)
+}
+
+func environment(overrides map[string]string) []string {
+	var env []string
+
+	for _, e := range os.Environ() {
+		if _, ok := overrides[strings.SplitN(e, "=", 2)[0]]; !ok {
+			env = append(env, e)
+		}
+	}
+
+	for k, v := range overrides {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return env
+}
+
+// expand replaces references to environment variables in s.  A reference of the form ${NAME:-default} is replaced by
+// default if NAME is unset or empty.
+func expand(s string) string {
+	return os.Expand(s, func(key string) string {
+		if k := strings.SplitN(key, ":-", 2); len(k) == 2 {
+			return getenv(k[0], k[1])
+		}
+
+		return os.Getenv(key)
+	})
+}
+
+func getenv(key string, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+
+	return def
+}
+
+func javaExecutable() (string, error) {
+	for _, v := range []string{"JAVA_HOME", "JRE_HOME"} {
+		if h, ok := os.LookupEnv(v); ok && h != "" {
+			return filepath.Join(h, "bin", "java"), nil
+		}
+	}
+
+	j, err := exec.LookPath("java")
+	if err != nil {
+		return "", fmt.Errorf("unable to find java, set $JAVA_HOME or add java to $PATH: %w", err)
+	}
+
+	return j, nil
+}
+
+// JavaVersion returns the major version of Java named by JAVA_VERSION in the release file of a JRE, or 0 if there is
+// none.
+func JavaVersion(javaHome string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(javaHome, "release"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	m := javaVersion.FindStringSubmatch(string(b))
+	if m == nil {
+		return 0, nil
+	}
+
+	v := strings.Split(m[1], ".")
+	if v[0] == "1" && len(v) > 1 {
+		v = v[1:]
+	}
+
+	major, err := strconv.Atoi(strings.SplitN(strings.SplitN(v[0], "-", 2)[0], "+", 2)[0])
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse JAVA_VERSION %s: %w", m[1], err)
+	}
+
+	return major, nil
+}
+
+// setenvClasspath returns the CLASSPATH assigned in setenv.sh.  As with "catalina.sh", setenv.sh in CATALINA_BASE is
+// preferred to setenv.sh in CATALINA_HOME.
+func setenvClasspath(catalinaHome string, catalinaBase string) ([]string, error) {
+	file := filepath.Join(catalinaBase, "bin", "setenv.sh")
+	if ok, err := helper.FileExists(file); err != nil {
+		return nil, err
+	} else if !ok {
+		file = filepath.Join(catalinaHome, "bin", "setenv.sh")
+	}
+
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cp string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		m := classpath.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+
+		v, err := fields(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse CLASSPATH in %s: %w", file, err)
+		}
+
+		cp = os.Expand(strings.Join(v, " "), func(key string) string {
+			if key == "CLASSPATH" {
+				return cp
+			}
+			return os.Getenv(key)
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	for _, e := range filepath.SplitList(cp) {
+		if e != "" {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+// fields splits s into words as a shell would, honoring single quotes, double quotes, and backslash escapes.
+func fields(s string) ([]string, error) {
+	var (
+		f       []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				f = append(f, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+
+	if inWord {
+		f = append(f, current.String())
+	}
+
+	return f, nil
+}
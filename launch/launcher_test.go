@@ -0,0 +1,171 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLauncher(t *testing.T) {
+	spec.Run(t, "Launcher", func(t *testing.T, when spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			base string
+			home string
+			java string
+		)
+
+		it.Before(func() {
+			root := test.ScratchDir(t, "launcher")
+			base = filepath.Join(root, "base")
+			home = filepath.Join(root, "home")
+			java = filepath.Join(root, "java")
+
+			test.TouchFile(t, home, "bin", "bootstrap.jar")
+			test.TouchFile(t, home, "bin", "tomcat-juli.jar")
+			test.TouchFile(t, base, "conf", "logging.properties")
+			test.WriteFile(t, filepath.Join(base, "bin", "setenv.sh"), `#!/bin/sh
+
+CLASSPATH=%s`, filepath.Join(base, "bin", "tomcat-logging-support.jar"))
+			test.WriteFile(t, filepath.Join(java, "release"), `JAVA_VERSION="11.0.6"`)
+		})
+
+		it("creates java command line", func() {
+			defer test.ReplaceEnv(t, "CATALINA_BASE", base)()
+			defer test.ReplaceEnv(t, "CATALINA_HOME", home)()
+			defer test.ReplaceEnv(t, "JAVA_HOME", java)()
+			defer test.ReplaceEnv(t, "JAVA_OPTS", "-Dport.http=8080 -Dtest.key='test value'")()
+			defer test.ReplaceEnv(t, "CATALINA_OPTS", `-Xmx1G "-Dother.key=other value"`)()
+
+			l, err := launch.NewLauncher("/test-launcher-home")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(l.Command()).To(gomega.Equal([]string{
+				filepath.Join(java, "bin", "java"),
+				fmt.Sprintf("-Djava.util.logging.config.file=%s", filepath.Join(base, "conf", "logging.properties")),
+				"-Djava.util.logging.manager=org.apache.juli.ClassLoaderLogManager",
+				"-Dport.http=8080",
+				"-Dtest.key=test value",
+				"-Djdk.tls.ephemeralDHKeySize=2048",
+				"-Djava.protocol.handler.pkgs=org.apache.catalina.webresources",
+				"-Xmx1G",
+				"-Dother.key=other value",
+				"-classpath",
+				fmt.Sprintf("%s:%s:%s",
+					filepath.Join(base, "bin", "tomcat-logging-support.jar"),
+					filepath.Join(home, "bin", "bootstrap.jar"),
+					filepath.Join(home, "bin", "tomcat-juli.jar")),
+				fmt.Sprintf("-Dcatalina.base=%s", base),
+				fmt.Sprintf("-Dcatalina.home=%s", home),
+				fmt.Sprintf("-Djava.io.tmpdir=%s", filepath.Join(base, "temp")),
+				"org.apache.catalina.startup.Bootstrap",
+				"start",
+			}))
+
+			g.Expect(l.Env).To(gomega.ContainElement(fmt.Sprintf("CATALINA_BASE=%s", base)))
+			g.Expect(l.Env).To(gomega.ContainElement(fmt.Sprintf("JDK_JAVA_OPTIONS=%s", launch.JDKJavaOptions)))
+		})
+
+		it("defaults CATALINA_HOME and CATALINA_BASE", func() {
+			defer test.ReplaceEnv(t, "CATALINA_BASE", "")()
+			defer test.ReplaceEnv(t, "CATALINA_HOME", "")()
+			defer test.ReplaceEnv(t, "JAVA_HOME", java)()
+
+			l, err := launch.NewLauncher(home)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(l.Args).To(gomega.ContainElement(fmt.Sprintf("-Dcatalina.base=%s", home)))
+			g.Expect(l.Args).To(gomega.ContainElement(fmt.Sprintf("-Dcatalina.home=%s", home)))
+			g.Expect(l.Args).NotTo(gomega.ContainElement(gomega.HavePrefix("-Djava.util.logging.config.file")))
+		})
+
+		it("appends to $JDK_JAVA_OPTIONS", func() {
+			defer test.ReplaceEnv(t, "CATALINA_HOME", home)()
+			defer test.ReplaceEnv(t, "JAVA_HOME", java)()
+			defer test.ReplaceEnv(t, "JDK_JAVA_OPTIONS", "-Dtest.key=test-value")()
+
+			l, err := launch.NewLauncher(home)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(l.Env).To(gomega.ContainElement(fmt.Sprintf("JDK_JAVA_OPTIONS=-Dtest.key=test-value %s", launch.JDKJavaOptions)))
+		})
+
+		it("does not add $JDK_JAVA_OPTIONS before Java 9", func() {
+			test.WriteFile(t, filepath.Join(java, "release"), `JAVA_VERSION="1.8.0_242"`)
+			defer test.ReplaceEnv(t, "CATALINA_HOME", home)()
+			defer test.ReplaceEnv(t, "JAVA_HOME", java)()
+			defer test.ReplaceEnv(t, "JDK_JAVA_OPTIONS", "-Dtest.key=test-value")()
+
+			l, err := launch.NewLauncher(home)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(l.Env).To(gomega.ContainElement("JDK_JAVA_OPTIONS=-Dtest.key=test-value"))
+		})
+
+		it("does not add $JDK_JAVA_OPTIONS without release file", func() {
+			defer test.ReplaceEnv(t, "CATALINA_HOME", home)()
+			defer test.ReplaceEnv(t, "JAVA_HOME", "/test-java-home")()
+			defer test.ReplaceEnv(t, "JDK_JAVA_OPTIONS", "-Dtest.key=test-value")()
+
+			l, err := launch.NewLauncher(home)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(l.Env).To(gomega.ContainElement("JDK_JAVA_OPTIONS=-Dtest.key=test-value"))
+		})
+
+		it("expands and appends options", func() {
+			defer test.ReplaceEnv(t, "CATALINA_BASE", "")()
+			defer test.ReplaceEnv(t, "CATALINA_HOME", home)()
+			defer test.ReplaceEnv(t, "JAVA_HOME", java)()
+			defer test.ReplaceEnv(t, "JAVA_OPTS", "")()
+			defer test.ReplaceEnv(t, "CATALINA_OPTS", "-Xmx1G")()
+			defer test.ReplaceEnv(t, "TEST_PORT", "8001")()
+			defer test.ReplaceEnv(t, "TEST_UNSET", "")()
+
+			l, err := launch.NewLauncher(home, "-Dtest.port=${TEST_PORT:-8000} -Dtest.default=${TEST_UNSET:-8000}", "-Dtest.key='$TEST_PORT'")
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			g.Expect(l.Args[:6]).To(gomega.Equal([]string{
+				"-Djdk.tls.ephemeralDHKeySize=2048",
+				"-Djava.protocol.handler.pkgs=org.apache.catalina.webresources",
+				"-Xmx1G",
+				"-Dtest.port=8001",
+				"-Dtest.default=8000",
+				"-Dtest.key=8001",
+			}))
+		})
+
+		it("fails with unterminated quote", func() {
+			defer test.ReplaceEnv(t, "CATALINA_HOME", home)()
+			defer test.ReplaceEnv(t, "JAVA_HOME", java)()
+			defer test.ReplaceEnv(t, "JAVA_OPTS", `-Dtest.key="test value`)()
+
+			_, err := launch.NewLauncher(home)
+			g.Expect(err).To(gomega.MatchError(gomega.HavePrefix("unable to parse $JAVA_OPTS")))
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// PropertiesFile returns the catalina.properties file that credentials are written to at launch.  It is outside of
+// $CATALINA_BASE, which may be on a read-only file system, and Tomcat is pointed at it by CatalinaConfig.
+func PropertiesFile() string {
+	return filepath.Join(os.TempDir(), "tomcat", "catalina.properties")
+}
+
+// CatalinaConfig returns the environment that makes Tomcat load its catalina.properties from a file.  Tomcat sets each
+// of those properties as a system property, from which the placeholders in its configuration files are resolved.
+func CatalinaConfig(file string) map[string]string {
+	o := fmt.Sprintf("-Dcatalina.config=file:%s", file)
+	if strings.Contains(os.Getenv("JAVA_OPTS"), o) {
+		return nil
+	}
+
+	return map[string]string{"JAVA_OPTS": javaOpts(o)}
+}
+
+// WriteProperties writes properties to a catalina.properties file.  The properties are delimited by comments naming
+// the contributor, so that writing properties for the same contributor again replaces, rather than duplicates, the
+// earlier properties.  A file that does not exist is created, readable only by its owner, from the catalina.properties
+// of $CATALINA_BASE or $CATALINA_HOME so that the rest of Tomcat's configuration is unchanged.
+func WriteProperties(file string, contributor string, properties map[string]string) error {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		b, err = defaultProperties()
+	}
+	if err != nil {
+		return err
+	}
+
+	begin := fmt.Sprintf("# BEGIN %s\n", contributor)
+	end := fmt.Sprintf("# END %s\n", contributor)
+
+	s := string(b)
+	if start := strings.Index(s, begin); start >= 0 {
+		if stop := strings.Index(s[start:], end); stop >= 0 {
+			s = s[:start] + s[start+stop+len(end):]
+		}
+	}
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+
+	var keys []string
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var c strings.Builder
+	c.WriteString(begin)
+	for _, k := range keys {
+		c.WriteString(fmt.Sprintf("%s=%s\n", escapeProperty(k), escapeProperty(properties[k])))
+	}
+	c.WriteString(end)
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, []byte(s+c.String()), 0600)
+}
+
+func defaultProperties() ([]byte, error) {
+	for _, e := range []string{"CATALINA_BASE", "CATALINA_HOME"} {
+		d, ok := os.LookupEnv(e)
+		if !ok {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(d, "conf", "catalina.properties"))
+		if err == nil {
+			return b, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find catalina.properties in $CATALINA_BASE/conf or $CATALINA_HOME/conf")
+}
+
+func escapeProperty(s string) string {
+	var b strings.Builder
+
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case strings.ContainsRune(`\ =:#!`, r):
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				b.WriteString(fmt.Sprintf(`\u%04x`, u))
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
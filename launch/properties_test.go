@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestProperties(t *testing.T) {
+	spec.Run(t, "Properties", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var (
+			file string
+			root string
+		)
+
+		it.Before(func() {
+			root = test.ScratchDir(t, "properties")
+			file = filepath.Join(root, "tmp", "tomcat", "catalina.properties")
+		})
+
+		it("creates file from $CATALINA_BASE catalina.properties", func() {
+			test.WriteFile(t, filepath.Join(root, "base", "conf", "catalina.properties"), "test.base=test-value\n")
+			test.WriteFile(t, filepath.Join(root, "home", "conf", "catalina.properties"), "test.home=test-value\n")
+			defer test.ReplaceEnv(t, "CATALINA_BASE", filepath.Join(root, "base"))()
+			defer test.ReplaceEnv(t, "CATALINA_HOME", filepath.Join(root, "home"))()
+
+			g.Expect(launch.WriteProperties(file, "test", map[string]string{"test.key": "test-value"})).To(gomega.Succeed())
+
+			g.Expect(file).To(test.HaveContent(`test.base=test-value
+# BEGIN test
+test.key=test-value
+# END test
+`))
+			g.Expect(os.Stat(file)).To(gomega.WithTransform(func(i os.FileInfo) os.FileMode { return i.Mode().Perm() },
+				gomega.Equal(os.FileMode(0600))))
+		})
+
+		it("creates file from $CATALINA_HOME catalina.properties", func() {
+			test.WriteFile(t, filepath.Join(root, "home", "conf", "catalina.properties"), "test.home=test-value")
+			defer test.ReplaceEnv(t, "CATALINA_BASE", filepath.Join(root, "base"))()
+			defer test.ReplaceEnv(t, "CATALINA_HOME", filepath.Join(root, "home"))()
+
+			g.Expect(launch.WriteProperties(file, "test", map[string]string{"test.key": "test-value"})).To(gomega.Succeed())
+
+			g.Expect(file).To(test.HaveContent(`test.home=test-value
+# BEGIN test
+test.key=test-value
+# END test
+`))
+		})
+
+		it("fails without catalina.properties", func() {
+			defer test.ReplaceEnv(t, "CATALINA_BASE", filepath.Join(root, "base"))()
+			defer test.ReplaceEnv(t, "CATALINA_HOME", filepath.Join(root, "home"))()
+
+			g.Expect(launch.WriteProperties(file, "test", map[string]string{})).
+				To(gomega.MatchError("unable to find catalina.properties in $CATALINA_BASE/conf or $CATALINA_HOME/conf"))
+		})
+
+		it("replaces properties of the same contributor", func() {
+			test.WriteFile(t, file, `test.key=test-value
+# BEGIN test
+test.old=test-value
+# END test
+# BEGIN other
+test.other=test-value
+# END other
+`)
+
+			g.Expect(launch.WriteProperties(file, "test", map[string]string{"test.new": "test-value"})).To(gomega.Succeed())
+
+			g.Expect(file).To(test.HaveContent(`test.key=test-value
+# BEGIN other
+test.other=test-value
+# END other
+# BEGIN test
+test.new=test-value
+# END test
+`))
+		})
+
+		it("escapes keys and values", func() {
+			test.WriteFile(t, file, "")
+
+			g.Expect(launch.WriteProperties(file, "test", map[string]string{"test key": "a=b:c#d!e\\f\ng é"})).To(gomega.Succeed())
+
+			g.Expect(file).To(test.HaveContent(`# BEGIN test
+test\ key=a\=b\:c\#d\!e\\f\ng\ \u00e9
+# END test
+`))
+		})
+
+		it("returns catalina.config environment", func() {
+			defer test.ReplaceEnv(t, "JAVA_OPTS", "-Dtest.key=test-value")()
+
+			g.Expect(launch.CatalinaConfig(file)).
+				To(gomega.Equal(map[string]string{"JAVA_OPTS": "-Dtest.key=test-value -Dcatalina.config=file:" + file}))
+		})
+
+		it("does not duplicate catalina.config", func() {
+			defer test.ReplaceEnv(t, "JAVA_OPTS", "-Dcatalina.config=file:"+file)()
+
+			g.Expect(launch.CatalinaConfig(file)).To(gomega.BeNil())
+		})
+	}, spec.Report(report.Terminal{}))
+}
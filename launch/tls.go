@@ -0,0 +1,87 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/helper"
+)
+
+const (
+	// TLSService is the filter used to find the TLS service binding.
+	TLSService = "tls"
+
+	// CertificateCredential is the TLS service binding credential containing the PEM encoded certificate.
+	CertificateCredential = "certificate"
+
+	// CertificateChainCredential is the optional TLS service binding credential containing the PEM encoded certificate
+	// chain.
+	CertificateChainCredential = "certificate_chain"
+
+	// PrivateKeyCredential is the TLS service binding credential containing the PEM encoded private key.
+	PrivateKeyCredential = "private_key"
+
+	// CertificateFile is the name of the file the certificate is written to.
+	CertificateFile = "certificate.pem"
+
+	// CertificateChainFile is the name of the file the certificate chain is written to.
+	CertificateChainFile = "certificate-chain.pem"
+
+	// PrivateKeyFile is the name of the file the private key is written to.
+	PrivateKeyFile = "private-key.pem"
+
+	// TLSDirectoryProperty is the system property that the HTTPS connector reads the directory of the certificate and
+	// private key from.
+	TLSDirectoryProperty = "tomcat.tls.directory"
+)
+
+// TLSDirectory returns the directory that the certificate and private key are written to at launch.  It is outside of
+// $CATALINA_BASE so that the private key is never written into a layer and $CATALINA_BASE may be read-only.
+func TLSDirectory() string {
+	return filepath.Join(os.TempDir(), "tomcat", "tls")
+}
+
+// TLS writes the certificate, private key, and certificate chain of the TLS service binding to a directory.  It is
+// executed at launch so that none of the material is written into the image.
+func TLS(destination string) error {
+	c, ok, err := helper.FindServiceCredentials(TLSService, CertificateCredential, PrivateKeyCredential)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("unable to find a single %s service binding with %s and %s credentials",
+			TLSService, CertificateCredential, PrivateKeyCredential)
+	}
+
+	if err := writeCredential(c, CertificateCredential, filepath.Join(destination, CertificateFile), 0644); err != nil {
+		return err
+	}
+
+	if err := writeCredential(c, PrivateKeyCredential, filepath.Join(destination, PrivateKeyFile), 0600); err != nil {
+		return err
+	}
+
+	if _, ok := c[CertificateChainCredential]; ok {
+		return writeCredential(c, CertificateChainCredential, filepath.Join(destination, CertificateChainFile), 0644)
+	}
+
+	return nil
+}
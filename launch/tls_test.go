@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package launch_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestTLS(t *testing.T) {
+	spec.Run(t, "TLS", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		var destination string
+
+		it.Before(func() {
+			destination = filepath.Join(test.ScratchDir(t, "tls"), "tls")
+		})
+
+		it("fails without TLS service", func() {
+			defer test.ReplaceEnv(t, "CNB_SERVICES", `{}`)()
+
+			g.Expect(launch.TLS(destination)).
+				To(gomega.MatchError("unable to find a single tls service binding with certificate and private_key credentials"))
+		})
+
+		it("writes certificate and private key", func() {
+			defer test.ReplaceEnv(t, "CNB_SERVICES", `{
+  "user-provided": [
+    {
+      "binding_name": "tls",
+      "credentials": { "certificate": "test-certificate", "private_key": "test-private-key" }
+    }
+  ]
+}`)()
+
+			g.Expect(launch.TLS(destination)).To(gomega.Succeed())
+
+			g.Expect(filepath.Join(destination, "certificate.pem")).To(test.HaveContent("test-certificate"))
+			g.Expect(filepath.Join(destination, "private-key.pem")).To(test.HaveContent("test-private-key"))
+			g.Expect(filepath.Join(destination, "private-key.pem")).To(test.HavePermissions(0600))
+			g.Expect(filepath.Join(destination, "certificate-chain.pem")).NotTo(gomega.BeAnExistingFile())
+		})
+
+		it("writes certificate chain", func() {
+			defer test.ReplaceEnv(t, "CNB_SERVICES", `{
+  "user-provided": [
+    {
+      "binding_name": "tls",
+      "credentials": {
+        "certificate": "test-certificate",
+        "certificate_chain": "test-certificate-chain",
+        "private_key": "test-private-key"
+      }
+    }
+  ]
+}`)()
+
+			g.Expect(launch.TLS(destination)).To(gomega.Succeed())
+
+			g.Expect(filepath.Join(destination, "certificate-chain.pem")).To(test.HaveContent("test-certificate-chain"))
+		})
+	}, spec.Report(report.Terminal{}))
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/cloudfoundry/tomcat-cnb/launch"
+)
+
+func main() {
+	if err := l(os.Args[1:]); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "tomcat-launcher: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// l starts Tomcat.  Each argument is a string of JVM options, added after $CATALINA_OPTS, that process types are
+// launched with.
+func l(args []string) error {
+	e, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	// The launcher is contributed to $CATALINA_HOME/bin
+	launcher, err := launch.NewLauncher(filepath.Dir(filepath.Dir(e)), args...)
+	if err != nil {
+		return err
+	}
+
+	syscall.Umask(0027)
+	return syscall.Exec(launcher.Java, launcher.Command(), launcher.Env)
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/cloudfoundry/libcfbuildpack/v2/test"
+	"github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestLauncher(t *testing.T) {
+	spec.Run(t, "Launcher", func(t *testing.T, _ spec.G, it spec.S) {
+
+		g := gomega.NewWithT(t)
+
+		it("fails with unterminated quote in arguments", func() {
+			defer test.ReplaceEnv(t, "JAVA_HOME", "/test-java-home")()
+
+			g.Expect(l([]string{`-Dtest.key="test value`})).To(gomega.MatchError(gomega.HavePrefix("unable to parse")))
+		})
+	}, spec.Report(report.Terminal{}))
+}
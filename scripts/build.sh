@@ -9,3 +9,16 @@ fi
 
 GOOS="linux" go build -ldflags='-s -w' -o bin/build build/main.go
 GOOS="linux" go build -ldflags='-s -w' -o bin/detect detect/main.go
+GOOS="linux" go build -ldflags='-s -w' -o bin/tomcat-helper helper/main.go
+GOOS="linux" go build -ldflags='-s -w' -o bin/tomcat-launcher launcher/main.go
+
+# The KEYS of every Tomcat major version and a detached signature of every Tomcat distribution in buildpack.toml are
+# packaged so that distributions are verified before they are expanded.
+rm -f KEYS
+mkdir -p signatures
+for MAJOR in $(grep '^uri *= "https://archive.apache.org/dist/tomcat/' buildpack.toml | sed -E 's|.*/tomcat/tomcat-([0-9]+)/.*|\1|' | sort -u); do
+  curl --fail --silent --show-error --location "https://archive.apache.org/dist/tomcat/tomcat-${MAJOR}/KEYS" >> KEYS
+done
+for URI in $(grep '^uri *= "https://archive.apache.org/dist/tomcat/' buildpack.toml | sed -E 's|^uri *= "(.*)"|\1|'); do
+  curl --fail --silent --show-error --location --output "signatures/$(basename "${URI}").asc" "${URI}.asc"
+done